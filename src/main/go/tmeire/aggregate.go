@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Sample is a single, already parsed reading for a station.
+type Sample struct {
+	Name   []byte
+	Tenths int64
+}
+
+// Stats are the aggregated readings for a station, in tenths of a degree.
+type Stats struct {
+	Min, Max, Sum, Count int64
+}
+
+// Mean returns the mean in degrees, rounded to one decimal like the printed output.
+func (s Stats) Mean() float64 {
+	return rounding.round(float64(s.Sum)/float64(s.Count)) / 10.
+}
+
+// AggregateChannel aggregates samples from ch with the given number of workers until ch is closed.
+// The sample names are stored as is, so they must not be modified after being sent.
+func AggregateChannel(ch <-chan Sample, workers int) map[string]Stats {
+	var wg sync.WaitGroup
+	results := make(chan measurements, 1)
+
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			data := New()
+			for s := range ch {
+				data.Add(s.Name, s.Tenths)
+			}
+			results <- data
+			wg.Done()
+		}()
+	}
+
+	done := make(chan struct{})
+	data := New()
+	go collect(data, results, done)
+
+	wg.Wait()
+	close(results)
+
+	<-done
+	return data.Stats()
+}
+
+func (m measurements) Stats() map[string]Stats {
+	res := make(map[string]Stats)
+	for _, mm := range m.Flatten() {
+		res[string(mm.name)] = Stats{Min: mm.min, Max: mm.max, Sum: mm.sum, Count: mm.count}
+	}
+	return res
+}
+
+// Errors passed to Options.OnError for the malformed rows.
+var (
+	ErrMissingDelimiter   = errors.New("missing ; between the station name and temperature")
+	ErrEmptyName          = errors.New("empty station name")
+	ErrInvalidTemperature = errors.New("temperature is not a number with a single decimal")
+)
+
+// Options configure Aggregate.
+type Options struct {
+	// Workers is the number of goroutines parsing the input, one less than GOMAXPROCS if not set.
+	Workers int
+
+	// OnError is called with the line number, counting from 1, and the contents of every malformed row.
+	// Malformed rows are skipped either way, blank lines aren't reported. raw is only valid during the call.
+	OnError func(line int, raw []byte, err error)
+}
+
+// Aggregate aggregates the name;temperature rows in r, which may be compressed.
+func Aggregate(r io.Reader, opts Options) (map[string]Stats, error) {
+	p := &parser{onError: opts.OnError}
+	r, err := prepareInput(r, p)
+	if err != nil {
+		return nil, err
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultWorkers()
+	}
+	data, err := collectData(r, libraryBlockSize, workers, p)
+	if err != nil {
+		return nil, err
+	}
+	return data.Stats(), nil
+}
+
+// rowChecker reports the malformed rows read through it to onError, the workers skip them when parsing.
+type rowChecker struct {
+	r       io.Reader
+	onError func(line int, raw []byte, err error)
+	line    int
+	// partial is the start of a line continued by the next read.
+	partial []byte
+}
+
+func (c *rowChecker) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	data := b[:n]
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			c.partial = append(c.partial, data...)
+			break
+		}
+
+		line := data[:idx]
+		if len(c.partial) > 0 {
+			line = append(c.partial, line...)
+		}
+		c.check(line)
+		c.partial = c.partial[:0]
+		data = data[idx+1:]
+	}
+
+	if err == io.EOF && len(c.partial) > 0 {
+		c.check(c.partial)
+		c.partial = c.partial[:0]
+	}
+	return n, err
+}
+
+func (c *rowChecker) check(line []byte) {
+	c.line++
+	if len(line) == 0 {
+		return
+	}
+
+	name, temp, ok := bytes.Cut(line, []byte{';'})
+	switch {
+	case !ok:
+		c.onError(c.line, line, ErrMissingDelimiter)
+	case len(name) == 0:
+		c.onError(c.line, line, ErrEmptyName)
+	case !isTemperature(temp):
+		c.onError(c.line, line, ErrInvalidTemperature)
+	}
+}
+
+// AggregateReaderAt aggregates the size bytes of measurements in r by splitting them into a range per worker,
+// aligned to line boundaries, and reading and parsing the ranges concurrently.
+func AggregateReaderAt(r io.ReaderAt, size int64, workers int) (map[string]Stats, error) {
+	data, err := aggregateRanges(r, size, workers, workers, libraryBlockSize, &parser{})
+	if err != nil {
+		return nil, err
+	}
+	return data.Stats(), nil
+}
+
+// aggregateRanges reads the line aligned ranges of r with a sequential reader each, all feeding the same workers.
+func aggregateRanges(r io.ReaderAt, size int64, readers, workers int, blockSize int, p *parser) (measurements, error) {
+	bounds, err := splitRanges(r, size, max(readers, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan measurements, 1)
+	inputs := make(chan []byte)
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go processBlocks(inputs, 0, 0, results, &wg, p)
+	}
+
+	done := make(chan struct{})
+	data := New()
+	go collect(data, results, done)
+
+	errs := make([]error, len(bounds)-1)
+	var readWg sync.WaitGroup
+	for i := range errs {
+		readWg.Add(1)
+		go func(i int) {
+			defer readWg.Done()
+			section := io.NewSectionReader(r, bounds[i], bounds[i+1]-bounds[i])
+			errs[i] = sendBlocks(section, blockSize, p, inputs)
+		}(i)
+	}
+	readWg.Wait()
+	close(inputs)
+
+	wg.Wait()
+	close(results)
+
+	<-done
+	return data, errors.Join(errs...)
+}
+
+// aggregateBytes aggregates b in place, without copying it into blocks, with a line aligned range per worker.
+// The station names point into b, so it must outlive the results.
+func aggregateBytes(b []byte, workers int, p *parser) measurements {
+	// Cut off a last line without a newline, only that one is copied to add it
+	var last []byte
+	if end := bytes.LastIndexByte(b, '\n'); end < len(b)-1 {
+		last = append(b[end+1:len(b):len(b)], '\n')
+		b = b[:end+1]
+	}
+	// Splitting a byte slice can't fail
+	bounds, _ := splitRanges(bytes.NewReader(b), int64(len(b)), max(workers, 1))
+
+	var wg sync.WaitGroup
+	results := make(chan measurements, 1)
+	inputs := make(chan []byte)
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go processBlocks(inputs, 0, 0, results, &wg, p)
+	}
+
+	done := make(chan struct{})
+	data := New()
+	go collect(data, results, done)
+
+	for i := 0; i < len(bounds)-1; i++ {
+		if bounds[i] < bounds[i+1] {
+			inputs <- b[bounds[i]:bounds[i+1]]
+		}
+	}
+	if last != nil {
+		inputs <- last
+	}
+	close(inputs)
+
+	wg.Wait()
+	close(results)
+
+	<-done
+	return data
+}
+
+// splitRanges returns the boundaries of n ranges of about the same size covering [0,size).
+// Every boundary but the first and last is moved forward to the start of the next line.
+func splitRanges(r io.ReaderAt, size int64, n int) ([]int64, error) {
+	bounds := []int64{0}
+	for i := 1; i < n; i++ {
+		off, err := nextLineStart(r, max(size*int64(i)/int64(n), bounds[len(bounds)-1]), size)
+		if err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, off)
+	}
+	return append(bounds, size), nil
+}
+
+// nextLineStart returns the offset just after the first newline at or after off, or size if there is none.
+func nextLineStart(r io.ReaderAt, off, size int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+
+	// Check the byte before off, a range starting right after a newline doesn't need to move
+	var probe [128]byte
+	for off--; off < size; off += int64(len(probe)) {
+		n, err := r.ReadAt(probe[:min(int64(len(probe)), size-off)], off)
+		if idx := bytes.IndexByte(probe[:n], '\n'); idx >= 0 {
+			return off + int64(idx) + 1, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+	}
+	return size, nil
+}
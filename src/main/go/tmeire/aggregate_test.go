@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestAggregateChannel(t *testing.T) {
+	input := []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\nBulawayo;-0.1\nHamburg;34.2\n")
+
+	expected := New()
+	process(expected, input)
+
+	ch := make(chan Sample)
+	go func() {
+		for _, s := range []Sample{
+			{[]byte("Hamburg"), 120},
+			{[]byte("Bulawayo"), 89},
+			{[]byte("Palembang"), 388},
+			{[]byte("Hamburg"), -34},
+			{[]byte("Bulawayo"), -1},
+			{[]byte("Hamburg"), 342},
+		} {
+			ch <- s
+		}
+		close(ch)
+	}()
+
+	if got := AggregateChannel(ch, 3); !maps.Equal(got, expected.Stats()) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), got)
+	}
+}
+
+func TestAggregateReaderAt(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%17, i%80-40, i%10)
+	}
+	input := []byte(sb.String())
+
+	expected := New()
+	process(expected, input)
+
+	for _, workers := range []int{1, 3, 8, 2000} {
+		got, err := AggregateReaderAt(bytes.NewReader(input), int64(len(input)), workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(got, expected.Stats()) {
+			t.Errorf("Wrong aggregation with %d workers, expected: %v, got: %v", workers, expected.Stats(), got)
+		}
+	}
+}
+
+func TestAggregateRanges(t *testing.T) {
+	input := benchmarkInput()[:30000]
+	expected, err := collectData(bytes.NewReader(input), 1024, 1, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, readers := range []int{1, 2, 5} {
+		data, err := aggregateRanges(bytes.NewReader(input), int64(len(input)), readers, 2, 1024, &parser{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(data.Stats(), expected.Stats()) {
+			t.Errorf("Wrong aggregation with %d readers, expected: %v, got: %v", readers, expected.Stats(), data.Stats())
+		}
+	}
+}
+
+func BenchmarkReaders(b *testing.B) {
+	input := benchmarkInput()
+	for _, readers := range []int{1, 2} {
+		b.Run(fmt.Sprintf("readers=%d", readers), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if _, err := aggregateRanges(bytes.NewReader(input), int64(len(input)), readers, defaultWorkers(), 64*1024, &parser{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitRanges(t *testing.T) {
+	input := []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\n")
+	bounds, err := splitRanges(bytes.NewReader(input), int64(len(input)), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The raw boundary at 13 already starts a line, the one at 27 moves past the last newline
+	if expected := []int64{0, 13, 41, 41}; !slices.Equal(bounds, expected) {
+		t.Errorf("Wrong boundaries, expected: %v, got: %v", expected, bounds)
+	}
+}
+
+func TestAggregateOnError(t *testing.T) {
+	input := "Hamburg;12.0\nHamburg\n;1.0\n\nBulawayo;8.9\nBulawayo;abc\nHamburg;-3.4\nPalembang;38.8"
+
+	type report struct {
+		line int
+		raw  string
+		err  error
+	}
+	var reports []report
+	data, err := Aggregate(iotest.OneByteReader(strings.NewReader(input)), Options{
+		Workers: 3,
+		OnError: func(line int, raw []byte, err error) {
+			reports = append(reports, report{line, string(raw), err})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []report{
+		{2, "Hamburg", ErrMissingDelimiter},
+		{3, ";1.0", ErrEmptyName},
+		{6, "Bulawayo;abc", ErrInvalidTemperature},
+	}
+	if !slices.Equal(reports, expected) {
+		t.Errorf("Wrong malformed rows, expected: %v, got: %v", expected, reports)
+	}
+	stats := map[string]Stats{
+		"Hamburg":   {Min: -34, Max: 120, Sum: 86, Count: 2},
+		"Bulawayo":  {Min: 89, Max: 89, Sum: 89, Count: 1},
+		"Palembang": {Min: 388, Max: 388, Sum: 388, Count: 1},
+	}
+	if !maps.Equal(data, stats) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", stats, data)
+	}
+}
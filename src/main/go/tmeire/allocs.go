@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// phaseAllocs is the number and size of the heap allocations of a phase of --profile-allocs-per-phase.
+type phaseAllocs struct {
+	phase          string
+	mallocs, bytes uint64
+}
+
+func (a phaseAllocs) String() string {
+	return fmt.Sprintf("%s: %d allocs, %d bytes", a.phase, a.mallocs, a.bytes)
+}
+
+// profileAllocs aggregates r like collectData, but one phase after the other, to attribute the allocations to them
+// with runtime.ReadMemStats deltas: reading the blocks, parsing them into the result sets of workers, merging
+// those, and sorting and printing the results with print. As the phases don't overlap, it's slower and holds
+// the whole input in memory.
+func profileAllocs(r io.Reader, blockSize, workers int, p *parser, print func(results []*measurement) error) ([]phaseAllocs, error) {
+	var allocs []phaseAllocs
+	measure := func(phase string, fn func() error) error {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		err := fn()
+		runtime.ReadMemStats(&after)
+		allocs = append(allocs, phaseAllocs{phase, after.Mallocs - before.Mallocs, after.TotalAlloc - before.TotalAlloc})
+		return err
+	}
+
+	var blocks [][]byte
+	err := measure("reading", func() error {
+		r, err := prepareInput(r, p)
+		if err != nil {
+			return err
+		}
+		inputs := make(chan []byte)
+		done := make(chan struct{})
+		go func() {
+			for b := range inputs {
+				blocks = append(blocks, b)
+			}
+			close(done)
+		}()
+		err = readBlocks(r, blockSize, p, inputs)
+		<-done
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]measurements, max(workers, 1))
+	measure("parsing", func() error {
+		for i := range sets {
+			sets[i] = New()
+		}
+		for i, b := range blocks {
+			p.processBlock(sets[i%len(sets)], b, i)
+		}
+		return nil
+	})
+
+	data := New()
+	measure("merging", func() error {
+		for _, set := range sets {
+			data.Merge(set)
+		}
+		return nil
+	})
+
+	err = measure("printing", func() error {
+		return print(sortedResults(data))
+	})
+	return allocs, err
+}
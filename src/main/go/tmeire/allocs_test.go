@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestProfileAllocs(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%97, i%80-40, i%10)
+	}
+	input := sb.String()
+	expected := New()
+	process(expected, []byte(input))
+
+	var printed map[string]Stats
+	allocs, err := profileAllocs(strings.NewReader(input), 1024, 3, &parser{}, func(results []*measurement) error {
+		data := New()
+		for _, m := range results {
+			data.AddMeasurement(m)
+		}
+		printed = data.Stats()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(printed, expected.Stats()) {
+		t.Errorf("Wrong aggregation of the phased run")
+	}
+
+	phases := []string{"reading", "parsing", "merging", "printing"}
+	if len(allocs) != len(phases) {
+		t.Fatalf("Wrong phases, expected: %v, got: %v", phases, allocs)
+	}
+	for i, a := range allocs {
+		if a.phase != phases[i] {
+			t.Errorf("Wrong phase %d, expected: %s, got: %s", i, phases[i], a.phase)
+		}
+		// The counts are unsigned, but a negative delta would wrap around to a huge one
+		if a.mallocs > 1<<40 || a.bytes > 1<<40 {
+			t.Errorf("Wrong allocations in the %s phase, got: %v", a.phase, a)
+		}
+		// Merging takes over the buckets of the workers, it only allocates to grow the ones the collector already has
+		if a.phase != "merging" && (a.mallocs == 0 || a.bytes == 0) {
+			t.Errorf("Expected allocations in the %s phase, got: %v", a.phase, a)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// benchBlockSize is the block size of a bench run, the generator fills the blocks as fast as it can so there's
+// no need for the huge blocks hiding disk latency.
+const benchBlockSize = 64 * 1024 * 1024
+
+type benchResult struct {
+	rows, bytes int64
+	stations    int
+	elapsed     time.Duration
+}
+
+func (r benchResult) String() string {
+	secs := r.elapsed.Seconds()
+	return fmt.Sprintf("rows=%d stations=%d elapsed=%v rows/sec=%.0f MB/sec=%.1f",
+		r.rows, r.stations, r.elapsed, float64(r.rows)/secs, float64(r.bytes)/secs/1e6)
+}
+
+// runBenchCommand runs calc bench with its own flags in args.
+func runBenchCommand(args []string, p *parser) (benchResult, error) {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	rows := fs.Float64("rows", 1e9, "generate `N` rows, like 1e9")
+	stations := fs.Int("stations", 10000, "spread the rows over `N` stations")
+	seed := fs.Int64("seed", 1, "seed of the generated readings")
+	if err := fs.Parse(args); err != nil {
+		return benchResult{}, err
+	}
+	if *rows < 0 || *stations <= 0 {
+		return benchResult{}, fmt.Errorf("invalid bench of %v rows over %d stations", *rows, *stations)
+	}
+	return runBench(int64(*rows), *stations, *seed, p)
+}
+
+// runBench aggregates rows generated in memory and piped straight into the aggregation, to measure the whole
+// pipeline without touching the disk.
+func runBench(rows int64, stations int, seed int64, p *parser) (benchResult, error) {
+	pr, pw := io.Pipe()
+	written := make(chan int64, 1)
+	go func() {
+		n, err := generateRows(pw, rows, stations, seed)
+		written <- n
+		pw.CloseWithError(err)
+	}()
+
+	start := time.Now()
+	data, err := aggregate(fullReader{pr}, benchBlockSize, p)
+	elapsed := time.Since(start)
+	// Unblock the generator if the aggregation stopped early
+	pr.Close()
+	n := <-written
+	if err != nil {
+		return benchResult{}, err
+	}
+
+	res := benchResult{bytes: n, elapsed: elapsed}
+	for _, m := range data.Flatten() {
+		res.rows += m.count
+		res.stations++
+	}
+	return res, nil
+}
+
+// generateRows writes rows random readings of stations stations to w and returns the number of bytes written.
+func generateRows(w io.Writer, rows int64, stations int, seed int64) (int64, error) {
+	r := rand.New(rand.NewSource(seed))
+	names := make([][]byte, stations)
+	for i := range names {
+		names[i] = []byte(fmt.Sprintf("Station%d", i))
+	}
+
+	bw := bufio.NewWriterSize(w, 1024*1024)
+	var written int64
+	var line []byte
+	for i := int64(0); i < rows; i++ {
+		t := r.Intn(1999) - 999
+		line = append(line[:0], names[r.Intn(stations)]...)
+		line = append(line, ';')
+		line = append(appendTenths(line, int64(t)), '\n')
+
+		n, err := bw.Write(line)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// fullReader fills the whole buffer on every read, so a pipe still hands out full blocks.
+type fullReader struct {
+	r io.Reader
+}
+
+func (f fullReader) Read(b []byte) (int, error) {
+	n, err := io.ReadFull(f.r, b)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunBench(t *testing.T) {
+	res, err := runBenchCommand([]string{"--rows", "1e5", "--stations", "100"}, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.rows != 100000 || res.stations != 100 {
+		t.Errorf("Wrong bench aggregation, expected: 100000 rows over 100 stations, got: %d rows over %d stations", res.rows, res.stations)
+	}
+	// Every row is at least Station0;0.0 and at most Station99;-99.9
+	if res.bytes < 100000*13 || res.bytes > 100000*18 {
+		t.Errorf("Wrong number of bytes generated for 100000 rows, got: %d", res.bytes)
+	}
+	if res.elapsed <= 0 || !strings.Contains(res.String(), "rows/sec=") {
+		t.Errorf("Wrong bench report: %s", res)
+	}
+}
+
+func TestGenerateRows(t *testing.T) {
+	var sb strings.Builder
+	n, err := generateRows(&sb, 1000, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != sb.Len() {
+		t.Errorf("Wrong byte count, expected: %d, got: %d", sb.Len(), n)
+	}
+
+	data := New()
+	process(data, []byte(sb.String()))
+	var rows int64
+	for _, m := range data.Flatten() {
+		rows += m.count
+	}
+	if rows != 1000 {
+		t.Errorf("Expected all generated rows to parse, expected: 1000, got: %d", rows)
+	}
+}
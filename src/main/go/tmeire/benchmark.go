@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+type timings struct {
+	samples          []time.Duration
+	min, median, max time.Duration
+}
+
+func (t timings) String() string {
+	return fmt.Sprintf("runs=%d min=%v median=%v max=%v", len(t.samples), t.min, t.median, t.max)
+}
+
+// benchmark calls run n times and returns the wall time statistics of the runs.
+func benchmark(n int, run func()) timings {
+	var t timings
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		run()
+		t.samples = append(t.samples, time.Since(start))
+	}
+
+	sorted := slices.Clone(t.samples)
+	slices.Sort(sorted)
+	t.min, t.max = sorted[0], sorted[len(sorted)-1]
+	if len(sorted)%2 == 1 {
+		t.median = sorted[len(sorted)/2]
+	} else {
+		t.median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	return t
+}
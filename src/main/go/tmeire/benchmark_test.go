@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBenchmark(t *testing.T) {
+	var runs int
+	res := benchmark(2, func() {
+		runs++
+		time.Sleep(time.Duration(runs) * time.Millisecond)
+	})
+
+	if runs != 2 {
+		t.Errorf("Wrong number of runs, expected: 2, got: %d", runs)
+	}
+	if len(res.samples) != 2 {
+		t.Fatalf("Wrong number of samples, expected: 2, got: %d", len(res.samples))
+	}
+	if res.min != min(res.samples[0], res.samples[1]) || res.max != max(res.samples[0], res.samples[1]) {
+		t.Errorf("Wrong min/max for samples %v: %v/%v", res.samples, res.min, res.max)
+	}
+	if res.median != (res.samples[0]+res.samples[1])/2 {
+		t.Errorf("Wrong median for samples %v: %v", res.samples, res.median)
+	}
+}
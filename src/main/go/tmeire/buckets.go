@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// bucketStats describes how the stations spread over the buckets of measurements.
+type bucketStats struct {
+	stations, buckets int
+	// used is the number of buckets with a station, longest the most stations in a single one.
+	used, longest int
+	// collisions is the number of stations sharing their bucket with another station.
+	collisions int
+}
+
+func (data measurements) bucketStats() bucketStats {
+	s := bucketStats{buckets: len(data)}
+	for _, b := range data {
+		if b == nil || len(b.data) == 0 {
+			continue
+		}
+		s.stations += len(b.data)
+		s.used++
+		s.longest = max(s.longest, len(b.data))
+		if len(b.data) > 1 {
+			s.collisions += len(b.data)
+		}
+	}
+	return s
+}
+
+func (s bucketStats) String() string {
+	return fmt.Sprintf("stations=%d buckets=%d used=%d longest=%d collisions=%d", s.stations, s.buckets, s.used, s.longest, s.collisions)
+}
+
+// crowdedStations returns up to n stations of the buckets with the most stations, which take the longest
+// to look up, along with the number of stations in their bucket.
+func (data measurements) crowdedStations(n int) (stations []*measurement, chains []int) {
+	var crowded []*bucket
+	for _, b := range data {
+		if b != nil && len(b.data) > 0 {
+			crowded = append(crowded, b)
+		}
+	}
+	slices.SortStableFunc(crowded, func(b1, b2 *bucket) int { return len(b2.data) - len(b1.data) })
+
+	for _, b := range crowded {
+		for _, m := range b.data {
+			if len(stations) == n {
+				return stations, chains
+			}
+			stations = append(stations, m)
+			chains = append(chains, len(b.data))
+		}
+	}
+	return stations, chains
+}
+
+// writeCrowdedStations writes a name chain=N line for each of crowdedStations.
+func writeCrowdedStations(w io.Writer, data measurements, n int, eol string) error {
+	bw := bufio.NewWriter(w)
+	stations, chains := data.crowdedStations(n)
+	for i, m := range stations {
+		fmt.Fprintf(bw, "%s chain=%d%s", m.name, chains[i], eol)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFNVConstants(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i, i%100-50, i%10)
+	}
+	input := []byte(sb.String())
+
+	defer func(offset, prime uint64) { fnvOffset, fnvPrime = offset, prime }(fnvOffset, fnvPrime)
+
+	standard := New()
+	process(standard, input)
+
+	// A prime of 1 reduces the hash to the xor of the bytes, so many names collide
+	fnvOffset, fnvPrime = 0, 1
+	weak := New()
+	process(weak, input)
+
+	if !maps.Equal(weak.Stats(), standard.Stats()) {
+		t.Errorf("Expected identical results with different hash constants")
+	}
+	s1, s2 := standard.bucketStats(), weak.bucketStats()
+	t.Logf("standard: %v, weak: %v", s1, s2)
+	if s1.stations != 2000 || s2.stations != 2000 {
+		t.Errorf("Wrong number of stations, expected: 2000, got: %d and %d", s1.stations, s2.stations)
+	}
+	if s2.collisions <= s1.collisions || s2.longest <= s1.longest {
+		t.Errorf("Expected more collisions with the weak constants, got: %v and %v", s1, s2)
+	}
+}
+
+func TestCrowdedStations(t *testing.T) {
+	defer func(offset, prime uint64) { fnvOffset, fnvPrime = offset, prime }(fnvOffset, fnvPrime)
+
+	// With a prime of 1 the hash is the xor of the bytes, so anagrams collide
+	fnvOffset, fnvPrime = 0, 1
+	data := New()
+	process(data, []byte("Hamburg;12.0\nabc;1.0\nOslo;2.0\ncab;3.0\nbca;4.0\nOslo;5.0\nLima;6.0\nmaLi;7.0\n"))
+
+	var sb strings.Builder
+	if err := writeCrowdedStations(&sb, data, 5, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "abc chain=3\ncab chain=3\nbca chain=3\n"
+	if !strings.HasPrefix(sb.String(), expected) {
+		t.Errorf("Wrong crowded stations, expected them to start with:\n%s\ngot:\n%s", expected, sb.String())
+	}
+	if got := strings.Split(sb.String(), "\n")[3:5]; !slices.Equal(got, []string{"Lima chain=2", "maLi chain=2"}) {
+		t.Errorf("Wrong stations after the longest chain, got: %v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// blockCache keeps a dump of the results of every block in dir, keyed by a hash of the block, so a re-run on
+// the same input skips parsing the blocks that didn't change. Like for dumps, only the min, max, sum, count
+// and sum of squares survive the cache.
+type blockCache struct {
+	dir string
+	// options is part of every key, as the parser options change the results of a block.
+	options string
+
+	hits, misses atomic.Int64
+}
+
+func newBlockCache(dir string, p *parser) (*blockCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var clamp [2]int64
+	if p.clamp != nil {
+		clamp = *p.clamp
+	}
+	return &blockCache{
+		dir: dir,
+		options: fmt.Sprintf("v%d paired=%v preaggregated=%v ndjson=%v column=%d first=%v tenths=%v nfc=%v lenient=%v clamp=%v empty=%d",
+			dumpVersion, p.pairedLines, p.preaggregated, p.ndjson, p.valueColumn, p.valueFirst, p.tenthsInput, p.nfc, p.lenient, clamp, p.emptyName),
+	}, nil
+}
+
+func (c *blockCache) path(block []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.options))
+	h.Write([]byte{0})
+	h.Write(block)
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil))+".bin")
+}
+
+// process adds the results of block to data, from the cache if it has them.
+func (c *blockCache) process(p *parser, data measurements, block []byte) {
+	path := c.path(block)
+	if results, err := loadDumpFile(path, false); err == nil {
+		c.hits.Add(1)
+		for _, m := range results {
+			data.AddMeasurement(m)
+		}
+		return
+	}
+	c.misses.Add(1)
+
+	res := New()
+	p.process(res, block)
+	// Failing to cache a block only costs the parsing of it on the next run
+	_ = c.store(path, res.Flatten())
+	data.Merge(res)
+}
+
+// store writes the dump through a temporary file, so a concurrent run never loads a partial dump.
+func (c *blockCache) store(path string, results []*measurement) error {
+	f, err := os.CreateTemp(c.dir, "block-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	if err := writeBufferedDumpFile(tmp, results); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
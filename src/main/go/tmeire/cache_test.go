@@ -0,0 +1,77 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockCache(t *testing.T) {
+	input := string(benchmarkInput()[:20000])
+	expected, err := collectData(strings.NewReader(input), 1024, 3, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stddevs := make(map[string]float64)
+	for _, m := range expected.Flatten() {
+		stddevs[string(m.name)] = m.stddev()
+	}
+
+	dir := t.TempDir()
+	run := func(input string) (measurements, *blockCache) {
+		p := &parser{}
+		if p.cache, err = newBlockCache(dir, p); err != nil {
+			t.Fatal(err)
+		}
+		data, err := collectData(strings.NewReader(input), 1024, 3, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data, p.cache
+	}
+
+	first, cache := run(input)
+	if cache.hits.Load() != 0 || cache.misses.Load() == 0 {
+		t.Errorf("Expected the first run to miss every block, got: %d hits, %d misses", cache.hits.Load(), cache.misses.Load())
+	}
+	blocks := cache.misses.Load()
+
+	second, cache := run(input)
+	if cache.hits.Load() != blocks || cache.misses.Load() != 0 {
+		t.Errorf("Expected the second run to hit all %d blocks, got: %d hits, %d misses", blocks, cache.hits.Load(), cache.misses.Load())
+	}
+	for _, data := range []measurements{first, second} {
+		if !maps.Equal(data.Stats(), expected.Stats()) {
+			t.Errorf("Wrong cached aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+		}
+		for _, m := range data.Flatten() {
+			if m.stddev() != stddevs[string(m.name)] {
+				t.Errorf("Wrong cached stddev for %s, expected: %v, got: %v", m.name, stddevs[string(m.name)], m.stddev())
+			}
+		}
+	}
+
+	// Only the block with the changed reading misses
+	changed := strings.Replace(input, "Station0;-50.0", "Station0;-51.0", 1)
+	if _, cache = run(changed); cache.misses.Load() != 1 || cache.hits.Load() != blocks-1 {
+		t.Errorf("Expected a single changed block, got: %d hits, %d misses", cache.hits.Load(), cache.misses.Load())
+	}
+}
+
+func TestCacheDirKeepsSEM(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(input, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHamburg;20.1\nBulawayo;9.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	expected := "station,min,mean,max,sem\nBulawayo,8.9,9.2,9.5,0.212\nHamburg,-3.4,9.6,20.1,5.627\n"
+	for _, run := range []string{"first", "cached"} {
+		if out := string(runCalc(t, nil, "--cache-dir", dir, "--sem", input)); out != expected {
+			t.Errorf("Wrong %s run, expected: %q, got: %q", run, expected, out)
+		}
+	}
+}
@@ -1,35 +1,101 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
 	"errors"
+	"flag"
 	"fmt"
-	"hash"
-	"hash/fnv"
 	"io"
 	"log"
 	"math"
+	"math/bits"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
-	"runtime"
+	"os/signal"
+	"regexp"
 	"runtime/pprof"
 	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type measurement struct {
 	name                 []byte
 	min, max, sum, count int64
+	sumSq                int64
 	hash                 uint64
+
+	// seq orders the stations by their first reading, it is only tracked with --sort=insertion.
+	seq uint64
+
+	// hist is only tracked with --histogram.
+	hist histogram
+
+	// weighted is only tracked with calc merge --weights.
+	weighted *weightedMean
 }
 
-func (m *measurement) Print() {
-	fmt.Printf("%s=%.1f/%.1f/%.1f, ",
+// Print prints the min, mean and max, followed by the spread with the precision of the max if spread is set.
+func (m *measurement) Print(w io.Writer, prec precision, spread bool) {
+	fmt.Fprintf(w, "%s=%.*f/%.*f/%.*f",
 		string(m.name),
-		float64(m.min)/10.,
-		math.Round(float64(m.sum)/float64(m.count))/10.,
-		float64(m.max)/10.,
+		prec.min, float64(m.min)/10.,
+		prec.mean, m.meanPrecision(prec.mean),
+		prec.max, float64(m.max)/10.,
 	)
+	if spread {
+		fmt.Fprintf(w, "/%.*f", prec.max, float64(m.spread())/10.)
+	}
+	fmt.Fprint(w, ", ")
+}
+
+// PrintTenths prints the min, mean and max, and the spread if set, as integer tenths of a degree.
+func (m *measurement) PrintTenths(w io.Writer, spread bool) {
+	fmt.Fprintf(w, "%s=%d/%d/%d", string(m.name), m.min, m.meanTenths(), m.max)
+	if spread {
+		fmt.Fprintf(w, "/%d", m.spread())
+	}
+	fmt.Fprint(w, ", ")
+}
+
+// spread returns the range between the min and max in tenths of a degree.
+func (m *measurement) spread() int64 {
+	return m.max - m.min
+}
+
+// meanTenths returns the mean in tenths of a degree, rounded to an integer.
+func (m *measurement) meanTenths() int64 {
+	return int64(rounding.round(float64(m.sum) / float64(m.count)))
+}
+
+// mean returns the mean in degrees, rounded to one decimal.
+func (m *measurement) mean() float64 {
+	return rounding.round(float64(m.sum)/float64(m.count)) / 10.
+}
+
+// meanPrecision returns the mean in degrees, rounded to the given number of decimals.
+func (m *measurement) meanPrecision(decimals int) float64 {
+	return rounding.round(float64(m.sum)*math.Pow10(decimals-1)/float64(m.count)) / math.Pow10(decimals)
+}
+
+// stddev returns the population standard deviation in degrees.
+func (m *measurement) stddev() float64 {
+	mean := float64(m.sum) / float64(m.count)
+	variance := float64(m.sumSq)/float64(m.count) - mean*mean
+	return math.Sqrt(max(variance, 0)) / 10.
+}
+
+// sem returns the standard error of the mean in degrees.
+func (m *measurement) sem() float64 {
+	return m.stddev() / math.Sqrt(float64(m.count))
 }
 
 func (m *measurement) Merge(m1 *measurement) {
@@ -40,7 +106,27 @@ func (m *measurement) Merge(m1 *measurement) {
 		m.max = m1.max
 	}
 	m.sum += m1.sum
+	m.sumSq += m1.sumSq
 	m.count += m1.count
+	if m1.seq < m.seq {
+		m.seq = m1.seq
+	}
+
+	if m1.hist != nil {
+		if m.hist == nil {
+			m.hist = m1.hist
+		} else {
+			m.hist.Merge(m1.hist)
+		}
+	}
+	if m1.weighted != nil {
+		if m.weighted == nil {
+			m.weighted = m1.weighted
+		} else {
+			m.weighted.sum += m1.weighted.sum
+			m.weighted.weight += m1.weighted.weight
+		}
+	}
 }
 
 func main() {
@@ -61,32 +147,781 @@ func main() {
 		}()
 	}
 
-	if len(os.Args) != 2 {
+	manifestPath := flag.String("manifest", "", "write a JSON manifest of the inputs, flags, version and output hash of the run to this `path`")
+	sqlitePath := flag.String("sqlite", "", "also insert the results into a new results table of the SQLite database at this `path`")
+	dump := flag.String("dump", "", "also write the aggregated results as a binary dump to this `path`")
+	format := flag.String("format", "text", "output `format`: text, csv, tsv, ndjson, table, compact, a single line of station:min/mean/max fields, dump, a binary dump for calc reduce, or protobuf, length-delimited Station messages of station.proto")
+	header := flag.Bool("header", false, "start the csv and tsv output with a comment line describing the units")
+	outputPath := flag.String("o", "", "write the results to this `file` instead of stdout, gzip compressed if it ends in .gz")
+	gzipOutput := flag.Bool("gzip-output", false, "gzip compress the output file, whatever its name")
+	table := flag.Bool("table", false, "print the results as an aligned table, short for -format table")
+	csvColumns := flag.String("csv-columns", "station,min,mean,max", "comma separated `columns` for the csv and tsv formats: "+columnNames())
+	spread := flag.Bool("spread", false, "add the spread, the max minus the min, of every station to the output")
+	trimmedMean := flag.Float64("trimmed-mean", 0, "add the mean without the lowest and highest `PCT` percent readings of every station as the trimmed_mean column, printing csv instead of text")
+	expectCounts := flag.String("expect-counts", "", "check the row count of every station against the station,count lines of `file`, exiting with 5 on mismatches")
+	median := flag.Bool("median", false, "add the median of every station, estimated from its histogram, as the median column, printing csv instead of text")
+	distinctValues := flag.Bool("distinct-values", false, "add the number of distinct readings of every station as the distinct column, printing csv instead of text")
+	sem := flag.Bool("sem", false, "add the standard error of the mean to the csv and tsv columns, printing csv instead of text")
+	p := &parser{}
+	flag.BoolVar(&p.pairedLines, "paired-lines", false, "read the station name and its temperature from two consecutive lines")
+	flag.BoolVar(&p.preaggregated, "preaggregated", false, "read pre-aggregated name;sum;count rows instead of single readings")
+	flag.Func("input-format", "`format` of the input: text, name;temperature lines, or ndjson, JSON objects with station and temp fields", func(s string) error {
+		switch s {
+		case "text":
+			p.ndjson = false
+		case "ndjson":
+			p.ndjson = true
+		default:
+			return fmt.Errorf("unknown input format %q, expected text or ndjson", s)
+		}
+		return nil
+	})
+	flag.IntVar(&p.valueColumn, "value-column", 0, "aggregate the `N`th value after the station name, counting from 1, of rows with several values like name;temperature;humidity")
+	flag.BoolVar(&p.nfc, "nfc", false, "normalize the station names to Unicode NFC, merging names only differing in their composed or decomposed accents")
+	flag.BoolVar(&p.tenthsInput, "tenths-input", false, "read the temperatures as integer tenths of a degree without a decimal point, like Abha;183 for 18.3")
+	flag.BoolVar(&p.valueFirst, "value-first", false, "read temperature;name lines, with the temperature before the station name")
+	flag.BoolVar(&p.lenient, "lenient", false, "accept temperatures with leading zeros, a plus sign or more decimals, like +05.0 or 5.25, rounding the extra decimals with -rounding")
+	flag.Func("rounding", "`mode` of rounding the means, and readings with more than one decimal for the min and max: half-away, half-even or half-up", func(s string) error {
+		var err error
+		rounding, err = parseRoundingMode(s)
+		return err
+	})
+	flag.BoolVar(&p.histogram, "histogram", false, "track a histogram of the readings per station, included in the ndjson output")
+	degreeHist := flag.Bool("degree-histogram", false, "print the number of readings per whole degree across all stations instead of the results")
+	flag.Func("alert-above", "print an alert for every reading above this `temperature`", func(s string) error {
+		t, err := parseDegrees(s)
+		p.alertAbove = &t
+		return err
+	})
+	flag.Func("clamp", "limit every reading to the `MIN:MAX` range in degrees, like -99.9:99.9, before aggregating it", func(s string) error {
+		var err error
+		p.clamp, err = parseClamp(s)
+		return err
+	})
+	alertExit := flag.Bool("alert-exit", false, fmt.Sprintf("exit with status %d on the first alert", alertExitCode))
+	prec := precision{min: 1, mean: 1, max: 1}
+	flag.Var(&prec, "precision", "number of decimals per field in the text output, like `min=1,mean=2,max=1`")
+	tenthsInt := flag.Bool("tenths-int", false, "print the min, mean and max in the text format as integer tenths of a degree, like Abha=50/180/274")
+	splitBySign := flag.Bool("split-output-by-sign", false, "print the stations with a negative mean and the others in separate [cold] and [warm] sections")
+	var meanAbove, meanBelow *int64
+	flag.Func("mean-above", "only print the stations with a mean above this `temperature`", func(s string) error {
+		t, err := parseDegrees(s)
+		meanAbove = &t
+		return err
+	})
+	flag.Func("mean-below", "only print the stations with a mean below this `temperature`", func(s string) error {
+		t, err := parseDegrees(s)
+		meanBelow = &t
+		return err
+	})
+	var groupRegex *regexp.Regexp
+	flag.Func("group-regex", "aggregate the stations by the first capture group of this `regexp` in their names, like (\\w+?)_\\d+", func(s string) error {
+		var err error
+		groupRegex, err = compileGroupRegex(s)
+		return err
+	})
+	skipUngrouped := flag.Bool("skip-ungrouped", false, "with -group-regex, drop the stations whose names don't match instead of keeping their full name")
+	fingerprints := flag.Bool("fingerprint", false, "print a hash of the aggregates of every station and of the whole dataset instead of the results, to detect duplicate datasets")
+	compatJava := flag.Bool("compat-java", false, "print the text format exactly like the reference Java implementation, including its rounding")
+	outputEOL := flag.String("output-eol", "lf", "line `terminator` of the output: lf or crlf")
+	collateLocale := flag.String("collate", "", "sort the station names using the collation rules of this `locale` instead of by bytes")
+	warmupInput := flag.Bool("warmup", false, "read the whole file, or touch every page of the -shm segment, before aggregating it so disk latency doesn't skew the timings")
+	benchmarkMode := flag.Int("benchmark-mode", 0, "run the aggregation `N` times without output and report the wall time statistics")
+	repairDumps := flag.Bool("repair-dumps", false, "when merging, swap a dump entry's min and max if they are reversed and drop entries without readings instead of failing")
+	mergeTolerance := flag.Int64("merge-tolerance", 0, "when merging, warn about dump entries whose sum is more than `N` tenths per reading outside of their min and max")
+	gomaxprocs := flag.Int("gomaxprocs", 0, "set GOMAXPROCS to `N` and derive the worker count from it, instead of using the cgroup CPU quota")
+	partialOK := flag.Bool("partial-ok", false, "print the results read before a truncated input instead of failing")
+	profilePhases := flag.Bool("profile-allocs-per-phase", false, "aggregate reading, parsing, merging and printing one after the other, and print the allocations of every phase to stderr")
+	dryParse := flag.Bool("dry-parse", false, "only parse the file and print the number of rows, without aggregating them")
+	tarArchive := flag.String("tar", "", "aggregate the regular files in this tar `archive`, which may be gzipped, instead of a measurements file")
+	readers := flag.Int("readers", 1, "read the file with `N` sequential readers over disjoint ranges of it, to hide disk latency")
+	shmName := flag.String("shm", "", "aggregate the POSIX shared memory segment with this `name` in place, instead of a measurements file")
+	shmOffset := flag.Int64("shm-offset", 0, "with -shm, skip the first `N` bytes of the segment, like a header")
+	shmSize := flag.Int64("shm-size", 0, "with -shm, only aggregate `N` bytes of the segment after -shm-offset, 0 aggregates up to its end")
+	preprocessCmd := flag.String("preprocess", "", "aggregate the output of this `command`, like 'tr , .', run with the input file as its stdin")
+	tee := flag.String("tee", "", "also write the raw input to this `path`, e.g. to archive stdin while aggregating it")
+	flag.Int64Var(&serialThreshold, "serial-threshold", serialThreshold, "parse files smaller than `N` bytes without any workers, 0 always uses the workers")
+	processes := flag.Int("processes", 1, "split the file over `N` child processes, each aggregating a range of it, and merge their dumps")
+	rangeOffset := flag.Int64("offset", 0, "with -length, only aggregate the bytes of the file from this `offset`, used by -processes")
+	rangeLength := flag.Int64("length", -1, "only aggregate `N` bytes of the file from -offset and write them as a dump to stdout, used by -processes")
+	flag.Func("decrypt-key", "decrypt the input, encrypted with AES-CTR and starting with the IV, with the raw or hex encoded key in this `file`", func(s string) error {
+		var err error
+		p.decryptKey, err = loadKey(s)
+		return err
+	})
+	flag.StringVar(&p.gzipIndex, "gzip-index", "", "decompress a gzip input of several members in parallel, with the member offsets in the index `file`, built first if it doesn't index the input yet")
+	flag.Int64Var(&p.skipBytes, "skip-bytes", 0, "skip a header of `N` bytes, up to the end of its line, before the first record")
+	flag.Func("empty-name", "`policy` for lines with a delimiter but no station name: skip, keep or error", func(s string) error {
+		var err error
+		p.emptyName, err = parseEmptyNamePolicy(s)
+		return err
+	})
+	flag.BoolVar(&p.checkSorted, "check-sorted", false, "fail on the first line whose station name sorts before the one on the line before it")
+	showThroughput := flag.Bool("throughput", false, "print the rows/sec and MB/sec processed to stderr every second")
+	flag.Func("sort", "`order` of the results: name, or insertion for the order the stations first appear in the file", func(s string) error {
+		switch s {
+		case "name":
+			p.insertionOrder = false
+		case "insertion":
+			p.insertionOrder = true
+		default:
+			return fmt.Errorf("unknown sort order %q, expected name or insertion", s)
+		}
+		return nil
+	})
+	flag.BoolVar(&directIO, "direct-io", false, "read the input file with O_DIRECT, bypassing the page cache, for benchmarks of cold reads (linux only)")
+	flag.BoolVar(&radixSort, "radix-sort", false, "sort the results by name with a radix sort, faster than comparing names for millions of stations")
+	flag.BoolVar(&p.deterministic, "deterministic", false, "assign the blocks to the workers round robin instead of to the first idle one, for reproducible profiles")
+	flag.IntVar(&p.flushBytes, "worker-flush-interval", 0, "hand the results of a worker to the collector every `N` bytes processed to bound its memory, 0 only does so at the end")
+	flag.IntVar(&workerCount, "workers", 0, "parse with `N` goroutines, instead of one per CPU but one, capped to the number of blocks of the file")
+	flag.IntVar(&buckets, "buckets", buckets, "spread the stations over `N` hash buckets per worker, rounded up to a power of two")
+	flag.Uint64Var(&fnvOffset, "fnv-offset", fnvOffset, "offset basis of the FNV-1a hash of the station names")
+	flag.Uint64Var(&fnvPrime, "fnv-prime", fnvPrime, "prime of the FNV-1a hash of the station names")
+	showTieStats := flag.Bool("tie-stats", false, "check the min and max only ever widen, and print how often readings tie or beat them to stderr")
+	showBucketStats := flag.Bool("bucket-stats", false, "print how the stations spread over the hash buckets to stderr")
+	slowestKeys := flag.Int("slowest-keys", 0, "print the `N` stations in the buckets with the most stations, the slowest to look up, instead of the results")
+	flag.Func("keyset", "tune the buckets for a known set of station `names`: 10k for the 10,000 station variant", useKeyset)
+	cacheDir := flag.String("cache-dir", "", "cache the results of every block in this `directory`, so re-runs skip parsing the blocks that didn't change")
+	maxRuntime := flag.Duration("max-runtime", 0, "stop reading the input after this `duration`, print the results so far and exit with status "+strconv.Itoa(partialExitCode))
+	tail := flag.Int("tail", 0, "only aggregate the last `N` rows of the file")
+	watchDir := flag.String("watch", "", "aggregate the .gz segments in this `dir`, and the ones added to it later, printing the results every -watch-interval and on SIGHUP")
+	watchInterval := flag.Duration("watch-interval", 10*time.Second, "`interval` between the results printed by -watch")
+	followInterval := flag.Duration("follow", 0, "keep reading data appended to the file and print a snapshot of the results every `interval`")
+	config := flag.String("config", "", "read default flag values from this JSON `file` instead of "+configFile+" in the working or home directory")
+	flag.Parse()
+	if *config == "" {
+		*config = findConfig()
+	}
+	if *config != "" {
+		if err := applyConfig(flag.CommandLine, *config); err != nil {
+			panic(err)
+		}
+	}
+	configureProcs(*gomaxprocs)
+	if *showThroughput {
+		p.throughput = &throughput{}
+		stop := make(chan struct{})
+		defer close(stop)
+		go p.throughput.report(time.Second, stop, printRates(os.Stderr, time.Second))
+	}
+	if buckets <= 0 {
+		panic(fmt.Sprintf("invalid bucket count %d", buckets))
+	}
+	if *spread {
+		*csvColumns = addColumn(*csvColumns, "spread")
+	}
+	if *distinctValues {
+		*csvColumns = addColumn(*csvColumns, "distinct")
+		if *format == "text" {
+			*format = "csv"
+		}
+	}
+	if *median {
+		*csvColumns = addColumn(*csvColumns, "median")
+		if *format == "text" {
+			*format = "csv"
+		}
+	}
+	if *sem {
+		*csvColumns = addColumn(*csvColumns, "sem")
+		if *format == "text" {
+			*format = "csv"
+		}
+	}
+	columns, err := parseColumns(*csvColumns)
+	if err != nil {
+		panic(err)
+	}
+	if *trimmedMean != 0 {
+		if *trimmedMean < 0 || *trimmedMean >= 50 {
+			panic(fmt.Sprintf("invalid trimmed mean percentage %v, expected it in [0,50)", *trimmedMean))
+		}
+		columns = append(columns, trimmedMeanColumn(*trimmedMean))
+		if *format == "text" {
+			*format = "csv"
+		}
+	}
+	// The guards below need to know whether the histograms are tracked
+	if needsHistogram(columns) {
+		p.histogram = true
+	}
+	if *degreeHist {
+		p.histogram = true
+	}
+	if *showTieStats {
+		p.ties = &tieStats{}
+	}
+	if p.insertionOrder && (p.pairedLines || p.preaggregated || p.ndjson || *tarArchive != "" || *readers > 1 || *tail > 0 || *followInterval > 0 || *watchDir != "") {
+		panic("--sort=insertion only supports a single name;temperature file read by one reader")
+	}
+	if *processes > 1 && (p.pairedLines || p.decryptKey != nil || p.skipBytes > 0 || p.insertionOrder || p.histogram) {
+		panic("--processes can't split paired lines, encrypted input, a skipped header, keep the insertion order or track histograms")
+	}
+	if err := checkHistogramInput(p, flag.Arg(0)); err != nil {
+		panic(err)
+	}
+	if p.gzipIndex != "" && (p.decryptKey != nil || flag.Arg(0) == "-" || *watchDir != "" || *tarArchive != "" || *readers > 1 || *processes > 1 || *tail > 0 || *followInterval > 0 || *tee != "") {
+		panic("--gzip-index only supports a single unencrypted gzip file read by one reader")
+	}
+	if *preprocessCmd != "" && (*tee != "" || *tarArchive != "" || *shmName != "" || *readers > 1 || *processes > 1 || *tail > 0 || *followInterval > 0 || *watchDir != "" || p.gzipIndex != "") {
+		panic("--preprocess only supports a single input file read by one reader")
+	}
+	if *cacheDir != "" {
+		if p.histogram || p.alertAbove != nil || p.insertionOrder {
+			panic("--cache-dir can't cache histograms, alerts or the insertion order")
+		}
+		var err error
+		if p.cache, err = newBlockCache(*cacheDir, p); err != nil {
+			panic(err)
+		}
+	}
+	var expectedCounts map[string]int64
+	if *expectCounts != "" {
+		var err error
+		if expectedCounts, err = loadExpectedCounts(*expectCounts); err != nil {
+			panic(err)
+		}
+	}
+	p.onAlert = func(name []byte, temperature int64) {
+		fmt.Fprintf(os.Stderr, "alert: %s=%.1f\n", name, float64(temperature)/10.)
+		if *alertExit {
+			os.Exit(alertExitCode)
+		}
+	}
+	if *rangeLength >= 0 {
+		// A --processes child hands its range to the parent as a dump
+		if err := writeRangeDump(os.Stdout, flag.Arg(0), *rangeOffset, *rangeLength, p); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	eol, ok := lineTerminators[*outputEOL]
+	if !ok {
+		panic(fmt.Sprintf("unknown output line terminator %q", *outputEOL))
+	}
+	if *table {
+		*format = "table"
+	}
+
+	if *maxRuntime > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *maxRuntime)
+		defer cancel()
+		p.ctx = ctx
+	}
+	// Exit only after the deferred calls closing the output, and only if main returns normally
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	var stdout io.Writer = os.Stdout
+	if *outputPath != "" {
+		f, err := createOutput(*outputPath, *gzipOutput)
+		if err != nil {
+			panic(err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				panic(err)
+			}
+		}()
+		stdout = f
+	}
+	// The manifest records the hash of everything written to the output
+	outputHash := sha256.New()
+	if *manifestPath != "" {
+		stdout = io.MultiWriter(stdout, outputHash)
+	}
+	saveManifest := func(inputs []string) {
+		if *manifestPath == "" {
+			return
+		}
+		m, err := newManifest(inputs, flag.CommandLine, outputHash.Sum(nil))
+		if err != nil {
+			panic(err)
+		}
+		if err := m.write(*manifestPath); err != nil {
+			panic(err)
+		}
+	}
+	out := &output{format: *format, columns: columns, eol: eol, precision: prec, compatJava: *compatJava, tenthsInt: *tenthsInt, meanAbove: meanAbove, meanBelow: meanBelow, header: *header, spread: *spread}
+	if *collateLocale != "" {
+		if out.collator, err = newCollator(*collateLocale); err != nil {
+			panic(err)
+		}
+	}
+	if flag.Arg(0) == "bench" {
+		res, err := runBenchCommand(flag.Args()[1:], p)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(res)
+		return
+	}
+
+	if flag.Arg(0) == "repair" {
+		if err := runRepairCommand(flag.Args()[1:], stdout, os.Stderr); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "merge" {
+		weights, paths, err := parseMergeArgs(flag.Args()[1:])
+		if err != nil {
+			panic(err)
+		}
+		if weights != nil {
+			out.columns = append(out.columns, weightedMeanColumn)
+			if out.format == "text" {
+				out.format = "csv"
+			}
+		}
+		data := New()
+		if err := mergeDumps(data, paths, weights, *repairDumps, *mergeTolerance, os.Stderr); err != nil {
+			panic(err)
+		}
+		if err := out.write(stdout, sortedResults(data)); err != nil {
+			panic(err)
+		}
+		saveManifest(paths)
+		return
+	}
+
+	if flag.Arg(0) == "reduce" {
+		data := New()
+		if err := reduceFiles(data, flag.Args()[1:]); err != nil {
+			panic(err)
+		}
+		if err := out.write(stdout, sortedResults(data)); err != nil {
+			panic(err)
+		}
+		saveManifest(flag.Args()[1:])
+		return
+	}
+
+	if flag.Arg(0) == "kmerge" {
+		var readers []io.Reader
+		for _, filename := range flag.Args()[1:] {
+			f, err := os.Open(filename)
+			if err != nil {
+				panic(err)
+			}
+			defer f.Close()
+			readers = append(readers, bufio.NewReader(f))
+		}
+
+		bw := bufio.NewWriter(stdout)
+		cw, err := newCSVWriter(bw, columns, eol)
+		if err != nil {
+			panic(err)
+		}
+		if err := kmerge(readers, cw.Write); err != nil {
+			panic(err)
+		}
+		if err := cw.Flush(); err != nil {
+			panic(err)
+		}
+		if err := bw.Flush(); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if flag.NArg() != 1 && *tarArchive == "" && *shmName == "" && *watchDir == "" {
 		panic("missing measurements filename")
 	}
 
-	file, err := os.Open(os.Args[1])
+	if *warmupInput && *shmName == "" {
+		path := flag.Arg(0)
+		if *tarArchive != "" {
+			path = *tarArchive
+		}
+		if path != "-" {
+			if _, err := warmup(path); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	if *benchmarkMode > 0 {
+		fmt.Println(benchmark(*benchmarkMode, func() {
+			if _, err := aggregateFile(flag.Arg(0), p); err != nil {
+				panic(err)
+			}
+		}))
+		return
+	}
+
+	if *profilePhases {
+		file, err := openInput(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		allocs, err := profileAllocs(file, blockSize, defaultWorkers(), p, func(results []*measurement) error {
+			return out.write(stdout, results)
+		})
+		if err != nil {
+			panic(err)
+		}
+		for _, a := range allocs {
+			fmt.Fprintf(os.Stderr, "allocs: %v\n", a)
+		}
+		return
+	}
+
+	if *dryParse {
+		file, err := openInput(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		rows, err := countRows(file, blockSize, defaultWorkers())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("rows=%d\n", rows)
+		return
+	}
+
+	if *watchDir != "" {
+		refresh := make(chan os.Signal, 1)
+		signal.Notify(refresh, syscall.SIGHUP)
+		err := watch(*watchDir, *watchInterval, p, refresh, nil, os.Stderr, func(results []*measurement) {
+			if err := out.write(stdout, results); err != nil {
+				panic(err)
+			}
+		})
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *followInterval > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		err = follow(file, *followInterval, p, nil, func(results []*measurement) {
+			if err := out.write(stdout, results); err != nil {
+				panic(err)
+			}
+		})
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	var data measurements
+	partial := false
+	if *tail > 0 {
+		data = aggregateTail(flag.Arg(0), *tail, p)
+	} else {
+		if *tarArchive != "" {
+			data, err = aggregateTar(*tarArchive, blockSize, p)
+		} else if *shmName != "" {
+			var shared []byte
+			var unmap func() error
+			if shared, unmap, err = openShared(*shmName, *shmOffset, *shmSize); err == nil {
+				// The station names point into the segment until the results are written
+				defer unmap()
+				if *warmupInput {
+					touchPages(shared)
+				}
+				data = aggregateBytes(shared, defaultWorkers(), p)
+			}
+		} else if *processes > 1 {
+			data, err = aggregateProcesses(flag.Arg(0), *processes, childRunner(flag.Arg(0), os.Args[1:len(os.Args)-flag.NArg()]))
+		} else if *readers > 1 {
+			data, err = aggregateFileRanges(flag.Arg(0), *readers, p)
+		} else if *preprocessCmd != "" {
+			data, err = aggregatePreprocessed(flag.Arg(0), *preprocessCmd, p)
+		} else if *tee != "" {
+			data, err = aggregateTee(flag.Arg(0), *tee, blockSize, p)
+		} else {
+			data, err = aggregateFile(flag.Arg(0), p)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintf(os.Stderr, "partial: stopped after the max runtime of %v, printing the results read before it\n", *maxRuntime)
+			partial = true
+		} else if err != nil {
+			// A file truncated while reading is only reported, like an unexpected EOF with --partial-ok
+			if !errors.Is(err, errInputChanged) && (!*partialOK || !errors.Is(err, io.ErrUnexpectedEOF)) {
+				panic(err)
+			}
+			fmt.Fprintf(os.Stderr, "warning: %v, printing the results read before it\n", err)
+		}
+	}
+	if p.cache != nil {
+		fmt.Fprintf(os.Stderr, "cache: %d blocks hit, %d missed\n", p.cache.hits.Load(), p.cache.misses.Load())
+	}
+	if groupRegex != nil {
+		data = groupBy(data, groupRegex, *skipUngrouped)
+	}
+	if *showBucketStats {
+		fmt.Fprintln(os.Stderr, data.bucketStats())
+	}
+	if p.ties != nil {
+		fmt.Fprintf(os.Stderr, "ties: %v\n", p.ties)
+	}
+	if *slowestKeys > 0 {
+		if err := writeCrowdedStations(stdout, data, *slowestKeys, eol); err != nil {
+			panic(err)
+		}
+		return
+	}
+	var results []*measurement
+	if p.insertionOrder {
+		results = data.Flatten()
+		sortBySeq(results)
+	} else {
+		results = sortedResults(data)
+	}
+	if *dump != "" {
+		if err := writeDumpFile(*dump, results); err != nil {
+			panic(err)
+		}
+	}
+	if *sqlitePath != "" {
+		if err := writeSQLite(*sqlitePath, results); err != nil {
+			panic(err)
+		}
+	}
+	if *degreeHist {
+		err = writeDegreeHistogram(stdout, degreeHistogram(results), eol)
+	} else if *fingerprints {
+		err = writeFingerprints(stdout, results, eol)
+	} else if *splitBySign {
+		err = out.writeSplitBySign(stdout, results)
+	} else {
+		err = out.write(stdout, results)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if *tarArchive != "" {
+		saveManifest([]string{*tarArchive})
+	} else {
+		saveManifest(flag.Args())
+	}
+	if partial {
+		exitCode = partialExitCode
+	} else if expectedCounts != nil && checkCounts(results, expectedCounts, os.Stderr) > 0 {
+		exitCode = countMismatchExitCode
+	}
+}
+
+// skipHeader discards the first n bytes of r and, unless they end with a newline, the rest of the line after them.
+func skipHeader(r io.Reader, n int64) (io.Reader, error) {
+	if _, err := io.CopyN(io.Discard, r, n-1); err != nil {
+		return nil, fmt.Errorf("skipping a %d byte header: %w", n, err)
+	}
+	br := bufio.NewReader(r)
+	for {
+		_, err := br.ReadSlice('\n')
+		if err == nil || errors.Is(err, io.EOF) {
+			return br, nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+}
+
+// aggregateFileRanges aggregates the file with the given number of readers over disjoint ranges of it.
+// Unlike aggregateFile it can't read stdin or gzipped files.
+func aggregateFileRanges(filename string, readers int, p *parser) (measurements, error) {
+	file, err := openSequential(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return aggregateRanges(file, info.Size(), readers, workersFor(info.Size(), blockSize), blockSize, p)
+}
+
+// aggregateFile aggregates the measurements in the file, or stdin for "-", which is decompressed first if it's gzipped.
+// On a read error the results of all records read before it are returned along with the error.
+func aggregateFile(filename string, p *parser) (measurements, error) {
+	file, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if p.gzipIndex != "" {
+		return aggregateIndexedGzip(file, p.gzipIndex, p)
+	}
+	// Sniff the gzip header before checkSize switches the file to O_DIRECT, which fails unaligned reads
+	size, plain, err := plainSize(file, p)
+	if err != nil {
+		return nil, err
+	}
+	r, err := checkSize(file)
+	if err != nil {
+		return nil, err
+	}
+	if plain {
+		if useSerial(size) {
+			return aggregateSerial(r, p)
+		}
+		return aggregateWorkers(r, blockSize, workersFor(size, blockSize), p)
+	}
+	return aggregate(r, blockSize, p)
+}
+
+// plainSize returns the size of file if it's a regular file that's neither encrypted nor gzipped,
+// as the size on disk says nothing about the size of the input after decrypting or decompressing it.
+func plainSize(file *os.File, p *parser) (int64, bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if !info.Mode().IsRegular() || p.decryptKey != nil {
+		return 0, false, nil
+	}
+	gzipped, err := startsWithGzip(file)
+	if err != nil {
+		return 0, false, fmt.Errorf("reading the gzip header: %w", err)
+	}
+	return info.Size(), !gzipped, nil
+}
+
+// aggregateTee is aggregateFile, while also writing the raw input to teePath.
+func aggregateTee(filename, teePath string, blockSize int, p *parser) (measurements, error) {
+	file, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r, err := checkSize(file)
+	if err != nil {
+		return nil, err
+	}
+	tee, err := os.Create(teePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := aggregate(io.TeeReader(r, tee), blockSize, p)
+	if cerr := tee.Close(); err == nil {
+		err = cerr
+	}
+	return data, err
+}
+
+func openInput(filename string) (*os.File, error) {
+	if filename == "-" {
+		return os.Stdin, nil
+	}
+	return openSequential(filename)
+}
+
+func aggregate(r io.Reader, blockSize int, p *parser) (measurements, error) {
+	return aggregateWorkers(r, blockSize, defaultWorkers(), p)
+}
+
+// aggregateWorkers is aggregate with the given number of parsing goroutines.
+func aggregateWorkers(r io.Reader, blockSize int, workers int, p *parser) (measurements, error) {
+	r, err := prepareInput(r, p)
+	if err != nil {
+		return nil, err
+	}
+	return collectData(r, blockSize, workers, p)
+}
+
+// prepareInput wraps r in the readers decrypting, decompressing and checking the input for aggregate.
+func prepareInput(r io.Reader, p *parser) (io.Reader, error) {
+	var err error
+	if p.ctx != nil {
+		r = contextReader{p.ctx, r}
+	}
+	if p.decryptKey != nil {
+		if r, err = decrypt(r, p.decryptKey); err != nil {
+			return nil, err
+		}
+	}
+	if r, err = decompress(r); err != nil {
+		return nil, err
+	}
+	if p.skipBytes > 0 {
+		if r, err = skipHeader(r, p.skipBytes); err != nil {
+			return nil, err
+		}
+	}
+	if p.checkSorted {
+		r = &sortChecker{r: r}
+	}
+	if p.emptyName == emptyNameError {
+		r = &emptyNameChecker{r: r}
+	}
+	if p.onError != nil {
+		r = &rowChecker{r: r, onError: p.onError}
+	}
+	return r, nil
+}
+
+func aggregateTail(filename string, rows int, p *parser) measurements {
+	file, err := os.Open(filename)
 	if err != nil {
 		panic(err)
 	}
 	defer file.Close()
 
-	data := collectData(file, blockSize, runtime.NumCPU()-1)
-	printMeasurements(data)
+	info, err := file.Stat()
+	if err != nil {
+		panic(err)
+	}
+	b, err := tailRows(file, info.Size(), rows, tailChunkSize)
+	if err != nil {
+		panic(err)
+	}
+
+	data := New()
+	p.process(data, b)
+	return data
 }
 
+// alertExitCode is the exit status used by --alert-exit.
+const alertExitCode = 3
+
 // TODO: see if this can be further optimised, reads don't show up in the trace though
 const blockSize = 1024 * 1024 * 1024
 
-func collectData(file io.Reader, blockSize int, parallellism int) measurements {
+func collectData(file io.Reader, blockSize int, parallellism int, p *parser) (measurements, error) {
 	var wg sync.WaitGroup
 	results := make(chan measurements, 1)
 
 	// Spin up a limited number of goroutines to limit scheduling issues between them
 	inputs := make(chan []byte)
-	for i := 0; i < parallellism; i++ {
-		wg.Add(1)
-		go processBlocks(inputs, results, &wg)
+	if p.deterministic || p.insertionOrder {
+		workers := make([]chan []byte, parallellism)
+		for i := range workers {
+			workers[i] = make(chan []byte)
+			wg.Add(1)
+			go processBlocks(workers[i], i, parallellism, results, &wg, p)
+		}
+		go assignBlocks(inputs, workers)
+	} else {
+		for i := 0; i < parallellism; i++ {
+			wg.Add(1)
+			go processBlocks(inputs, 0, 0, results, &wg, p)
+		}
 	}
 
 	// One goroutine to collect all the result sets into one
@@ -94,44 +929,116 @@ func collectData(file io.Reader, blockSize int, parallellism int) measurements {
 	data := New()
 	go collect(data, results, done)
 
+	err := readBlocks(file, blockSize, p, inputs)
+
+	// Wait until all processing goroutines have finished, then close the results channel to make sure the collection goroutine can quit as well
+	wg.Wait()
+	close(results)
+
+	<-done
+	return data, err
+}
+
+// readBlocks reads file in blocks of up to blockSize bytes, sends every block cut off after its last full record to inputs
+// and closes inputs at the end of the file. The remainder of a block is carried over to the start of the next one.
+// A read error stops reading, after sending the full records read before it.
+func readBlocks(file io.Reader, blockSize int, p *parser, inputs chan<- []byte) error {
+	defer close(inputs)
+	return sendBlocks(file, blockSize, p, inputs)
+}
+
+// sendBlocks is readBlocks without closing inputs, for several readers sending to the same workers.
+func sendBlocks(file io.Reader, blockSize int, p *parser, inputs chan<- []byte) error {
 	var offset int
+	var read int64
 	var b1 = make([]byte, blockSize)
 	var b2 []byte
+	// The blocks holding the first and last record sent, to report the range read before an error
+	var first, last []byte
+	send := func(b []byte) {
+		if first == nil {
+			first = b
+		}
+		last = b
+		inputs <- b
+	}
 	for {
 		// Read the next block of the file
 		n, err := file.Read(b1[offset:])
+		read += int64(n)
 		if err != nil {
-			if !errors.Is(err, io.EOF) {
-				panic(err)
+			if errors.Is(err, io.EOF) {
+				// Whatever is left is the last record, even without a newline after it
+				if rest := b1[:offset+n]; len(rest) > 0 {
+					if rest[len(rest)-1] != '\n' {
+						rest = append(rest, '\n')
+					}
+					send(rest)
+				}
+				return nil
 			}
-			break
+			if ns := p.recordEnd(b1[:offset+n]); ns >= 0 {
+				send(b1[:ns+1])
+			}
+			if first == nil {
+				return fmt.Errorf("reading input after %d bytes, before the first complete record: %w", read, err)
+			}
+			return fmt.Errorf("reading input after %d bytes, the records from %q to %q are complete: %w",
+				read, p.firstRecord(first), p.lastRecord(last), err)
 		}
 
 		// Find the end of the last full measurement
-		ns := offset + n - 1
-		for i := ns; i >= 0; i-- {
-			if b1[i] == '\n' {
-				ns = i
-				break
-			}
+		ns := p.recordEnd(b1[:offset+n])
+		if ns < 0 && offset+n == len(b1) {
+			// A record longer than the block, parse the full block as is
+			ns = offset + n - 1
 		}
 
 		// Parse the block until the last full measurement & merge it into the main dataset
-		inputs <- b1[:ns+1]
+		if ns >= 0 {
+			send(b1[:ns+1])
+		}
 
 		// Create a new block for the next goroutine
 		b2, b1 = b1, make([]byte, blockSize)
 		copy(b1[0:(offset+n)-(ns+1)], b2[ns+1:offset+n])
 		offset = (offset + n) - (ns + 1)
 	}
-	close(inputs)
+}
 
-	// Wait until all processing goroutines have finished, then close the results channel to make sure the collection goroutine can quit as well
+// countRows parses the blocks of file like collectData, but only counts the rows.
+func countRows(file io.Reader, blockSize int, parallellism int) (int64, error) {
+	var wg sync.WaitGroup
+	var rows atomic.Int64
+
+	inputs := make(chan []byte)
+	for i := 0; i < parallellism; i++ {
+		wg.Add(1)
+		go func() {
+			var c rowCounter
+			for input := range inputs {
+				process(&c, input)
+			}
+			rows.Add(c.rows)
+			wg.Done()
+		}()
+	}
+
+	err := readBlocks(file, blockSize, &parser{}, inputs)
 	wg.Wait()
-	close(results)
+	return rows.Load(), err
+}
 
-	<-done
-	return data
+// assignBlocks sends block i of inputs to worker i % len(workers), and closes the workers once inputs is closed.
+func assignBlocks(inputs <-chan []byte, workers []chan []byte) {
+	i := 0
+	for input := range inputs {
+		workers[i%len(workers)] <- input
+		i++
+	}
+	for _, w := range workers {
+		close(w)
+	}
 }
 
 func collect(data measurements, results <-chan measurements, done chan struct{}) {
@@ -141,17 +1048,57 @@ func collect(data measurements, results <-chan measurements, done chan struct{})
 	close(done)
 }
 
-func processBlocks(inputs <-chan []byte, results chan<- measurements, wg *sync.WaitGroup) {
+// processBlocks aggregates the blocks from inputs. With insertionOrder, block and step number the blocks as assigned by assignBlocks.
+func processBlocks(inputs <-chan []byte, block, step int, results chan<- measurements, wg *sync.WaitGroup, p *parser) {
 	data := New()
 
+	processed := 0
 	for input := range inputs {
-		process(data, input)
+		p.processBlock(data, input, block)
+		block += step
+
+		processed += len(input)
+		if p.flushBytes > 0 && processed >= p.flushBytes {
+			results <- data
+			data = New()
+			processed = 0
+		}
 	}
 	results <- data
 	wg.Done()
 }
 
-func process(data measurements, b []byte) {
+// processBlock aggregates block, the blockth of the input with insertionOrder, into data.
+func (p *parser) processBlock(data measurements, input []byte, block int) {
+	if p.insertionOrder {
+		// A block is well below 4G lines, so the line within the block fits the lower 32 bits
+		p.processOrdered(data, input, uint64(block)<<32)
+	} else if p.cache != nil {
+		p.cache.process(p, data, input)
+	} else {
+		p.process(data, input)
+	}
+	if p.throughput != nil {
+		p.throughput.add(bytes.Count(input, []byte{'\n'}), len(input))
+	}
+}
+
+// rowSink receives the rows parsed by process, measurements aggregates them.
+type rowSink interface {
+	Add(name []byte, temperature int64) *measurement
+}
+
+// rowCounter only counts the rows, to measure the parsing without the aggregation.
+type rowCounter struct {
+	rows int64
+}
+
+func (c *rowCounter) Add(name []byte, temperature int64) *measurement {
+	c.rows++
+	return nil
+}
+
+func process[S rowSink](data S, b []byte) {
 	if len(b) > 0 && b[0] == '\n' {
 		b = b[1:]
 	}
@@ -162,6 +1109,11 @@ func process(data measurements, b []byte) {
 		case ';':
 			ne = i
 		case '\n':
+			// Skip blank lines, lines without a name or delimiter and lines without a full temperature
+			if ne <= ns || i-ne <= 3 {
+				ns = i + 1
+				continue
+			}
 			name := b[ns:ne]
 			temperature := int64(parseTemperature(b[ne+1 : i]))
 
@@ -171,20 +1123,46 @@ func process(data measurements, b []byte) {
 	}
 }
 
-func printMeasurements(data measurements) {
+func sortedResults(data measurements) []*measurement {
 	results := data.Flatten()
+	sortByName(results)
+	return results
+}
+
+// sortBySeq sorts the results in the order the stations first appeared, for --sort=insertion.
+func sortBySeq(results []*measurement) {
+	slices.SortFunc(results, func(m1 *measurement, m2 *measurement) int {
+		return cmp.Compare(m1.seq, m2.seq)
+	})
+}
+
+func sortByName(results []*measurement) {
+	if radixSort {
+		radixSortByName(results)
+		return
+	}
 	slices.SortFunc(results, func(m1 *measurement, m2 *measurement) int {
 		if string(m1.name) < string(m2.name) {
 			return -1
 		}
 		return 1
 	})
+}
 
-	print("{")
+func printMeasurements(w io.Writer, results []*measurement, prec precision, spread bool, eol string) {
+	fmt.Fprint(w, "{")
 	for _, k := range results {
-		k.Print()
+		k.Print(w, prec, spread)
 	}
-	print("}\n")
+	fmt.Fprint(w, "}"+eol)
+}
+
+func printTenths(w io.Writer, results []*measurement, spread bool, eol string) {
+	fmt.Fprint(w, "{")
+	for _, k := range results {
+		k.PrintTenths(w, spread)
+	}
+	fmt.Fprint(w, "}"+eol)
 }
 
 func parseTemperature(temp []byte) int64 {
@@ -204,27 +1182,56 @@ func parseTemperature(temp []byte) int64 {
 	return n
 }
 
-func namehash(name []byte) uint16 {
-	l := min(len(name), 4)
+// The FNV-1a offset basis and prime of namehash, only changed to experiment with the bucket layout.
+var (
+	fnvOffset uint64 = 14695981039346656037
+	fnvPrime  uint64 = 1099511628211
+)
 
-	var id uint16
-	for idx, b := range name[len(name)-l:] {
-		val := uint16(b)
-		if idx%2 == 0 {
-			val = val << 8
-		}
-		id = id & val
+// namehash returns the 64 bit FNV-1a hash of the full station name. Its low bits pick the bucket of the name.
+func namehash(name []byte) uint64 {
+	h := fnvOffset
+	for _, c := range name {
+		h ^= uint64(c)
+		h *= fnvPrime
 	}
-	return id
+	return h
 }
 
 type measurements []*bucket
 
+// buckets is the number of buckets of the measurements created by New.
+var buckets = 1 << 16
+
 func New() measurements {
-	return make([]*bucket, math.MaxUint16)
+	return NewBuckets(buckets)
+}
+
+// NewBuckets creates measurements with n buckets, rounded up to a power of two
+// so the bucket of a name is its hash masked to the table size.
+func NewBuckets(n int) measurements {
+	return make([]*bucket, 1<<bits.Len(uint(max(n, 1)-1)))
+}
+
+// bucket returns the bucket for the name hash, creating it if needed.
+func (m measurements) bucket(hash uint64) *bucket {
+	id := hash & uint64(len(m)-1)
+
+	if m[id] == nil {
+		m[id] = &bucket{}
+	}
+	return m[id]
 }
 
+// Merge merges res into mm. Measurements with a different number of buckets are merged per station.
 func (mm measurements) Merge(res measurements) {
+	if len(mm) != len(res) {
+		for _, m := range res.Flatten() {
+			mm.AddMeasurement(m)
+		}
+		return
+	}
+
 	for h, b := range res {
 		if b == nil {
 			continue
@@ -251,55 +1258,104 @@ func (m measurements) Flatten() []*measurement {
 	return res
 }
 
-func (m measurements) Add(name []byte, temperature int64) {
-	id := namehash(name)
+func (m measurements) Add(name []byte, temperature int64) *measurement {
+	hash := namehash(name)
+	return m.bucket(hash).AddNew(name, hash, temperature)
+}
+
+// AddMeasurement merges an already aggregated measurement into the results.
+func (m measurements) AddMeasurement(mm *measurement) {
+	mm.hash = namehash(mm.name)
+	m.bucket(mm.hash).Add(mm)
+}
 
-	if m[id] == nil {
-		m[id] = &bucket{fnv.New64a(), nil}
-	}
-	m[id].AddNew(name, temperature)
+// AddWeighted folds in count readings summing up to sum. As the individual readings are unknown,
+// their min and max are taken to be the rounded mean.
+func (m measurements) AddWeighted(name []byte, sum, count int64) {
+	hash := namehash(name)
+	m.bucket(hash).AddNewWeighted(name, hash, sum, count)
 }
 
+// maxBucketScan is the number of stations in a bucket above which it indexes them by name instead of
+// comparing them one by one, so colliding names don't turn lookups into linear scans.
+const maxBucketScan = 8
+
 type bucket struct {
-	hasher hash.Hash64
-	data   []*measurement
+	data []*measurement
+
+	// index maps the names to the measurements of data, once there are more than maxBucketScan of them.
+	index map[string]*measurement
 }
 
-func (b *bucket) Add(m *measurement) {
+// find returns the measurement of the station, or nil if the bucket doesn't have it yet.
+func (b *bucket) find(name []byte, hash uint64) *measurement {
+	if b.index != nil {
+		return b.index[string(name)]
+	}
 	for _, d := range b.data {
-		if m.hash == d.hash {
-			d.Merge(m)
-			return
+		if d.hash == hash && bytes.Equal(d.name, name) {
+			return d
 		}
 	}
+	return nil
+}
+
+func (b *bucket) insert(m *measurement) {
 	b.data = append(b.data, m)
+	if b.index != nil {
+		b.index[string(m.name)] = m
+	} else if len(b.data) > maxBucketScan {
+		b.index = make(map[string]*measurement, len(b.data))
+		for _, d := range b.data {
+			b.index[string(d.name)] = d
+		}
+	}
 }
 
-func (b *bucket) AddNew(name []byte, temperature int64) {
-	b.hasher.Reset()
-	b.hasher.Write(name)
-	hname := b.hasher.Sum64()
+func (b *bucket) Add(m *measurement) {
+	if d := b.find(m.name, m.hash); d != nil {
+		d.Merge(m)
+		return
+	}
+	b.insert(m)
+}
 
-	for _, d := range b.data {
-		if hname == d.hash {
-			if temperature < d.min {
-				d.min = temperature
-			}
-			if temperature > d.max {
-				d.max = temperature
-			}
-			d.sum += temperature
-			d.count++
-			return
+func (b *bucket) AddNew(name []byte, hash uint64, temperature int64) *measurement {
+	if d := b.find(name, hash); d != nil {
+		if temperature < d.min {
+			d.min = temperature
+		}
+		if temperature > d.max {
+			d.max = temperature
 		}
+		d.sum += temperature
+		d.sumSq += temperature * temperature
+		d.count++
+		return d
 	}
 
-	b.data = append(b.data, &measurement{
+	m := &measurement{
 		name:  name,
-		hash:  hname,
+		hash:  hash,
 		min:   temperature,
 		max:   temperature,
 		sum:   temperature,
+		sumSq: temperature * temperature,
 		count: 1,
+	}
+	b.insert(m)
+	return m
+}
+
+func (b *bucket) AddNewWeighted(name []byte, hash uint64, sum, count int64) {
+	mean := rounding.roundQuotient(sum, count)
+	b.Add(&measurement{
+		name:  name,
+		hash:  hash,
+		min:   mean,
+		max:   mean,
+		sum:   sum,
+		sumSq: mean * sum,
+		count: count,
 	})
 }
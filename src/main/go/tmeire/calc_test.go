@@ -0,0 +1,403 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProcessSkipsMalformedLines(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{name: "blank lines", input: "Hamburg;12.0\n\n\nBulawayo;8.9\n\n"},
+		{name: "no delimiter", input: "Hamburg;12.0\nHamburg\nBulawayo;8.9\n12.3\n"},
+		{name: "no name", input: ";1.0\nHamburg;12.0\nBulawayo;8.9\n"},
+		{name: "no temperature", input: "Hamburg;12.0\nHamburg;\nBulawayo;8.9\nBulawayo;1\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := New()
+			process(data, []byte(tc.input))
+
+			expected := map[string]Stats{
+				"Hamburg":  {Min: 120, Max: 120, Sum: 120, Count: 1},
+				"Bulawayo": {Min: 89, Max: 89, Sum: 89, Count: 1},
+			}
+			if got := data.Stats(); !maps.Equal(got, expected) {
+				t.Errorf("Wrong aggregation of %q, expected: %v, got: %v", tc.input, expected, got)
+			}
+		})
+	}
+}
+
+func TestLineLongerThanBlock(t *testing.T) {
+	// The malformed line spans several blocks, which are parsed as they are instead of waiting for its end
+	input := strings.Repeat("a", 5000) + "\nHamburg;12.0\nBulawayo;8.9\n"
+	data, err := collectData(strings.NewReader(input), 1024, 1, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Stats{
+		"Hamburg":  {Min: 120, Max: 120, Sum: 120, Count: 1},
+		"Bulawayo": {Min: 89, Max: 89, Sum: 89, Count: 1},
+	}
+	if got := data.Stats(); !maps.Equal(got, expected) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestAggregateTee(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%13, i%50-25, i%10)
+	}
+	input := sb.String()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "measurements.txt")
+	if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	teePath := filepath.Join(dir, "tee.txt")
+	data, err := aggregateTee(filename, teePath, 100, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(teePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != input {
+		t.Errorf("Tee'd input differs from the input, expected %d bytes, got %d", len(input), len(b))
+	}
+
+	expected := New()
+	process(expected, []byte(input))
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+}
+
+func TestSkipBytes(t *testing.T) {
+	header := "\x89BIN\x00\x01;99.9\x00\xff"
+	records := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	expected := map[string]Stats{
+		"Hamburg":  {Min: -34, Max: 120, Sum: 86, Count: 2},
+		"Bulawayo": {Min: 89, Max: 89, Sum: 89, Count: 1},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		skip  int64
+	}{
+		{name: "header and newline", input: header + "\n" + records, skip: int64(len(header) + 1)},
+		{name: "snap to the newline", input: header + "\n" + records, skip: 4},
+		{name: "header line of junk", input: header + "Junk;1.0\n" + records, skip: int64(len(header))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := aggregate(strings.NewReader(tc.input), 16, &parser{skipBytes: tc.skip})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(data.Stats(), expected) {
+				t.Errorf("Wrong aggregation, expected: %v, got: %v", expected, data.Stats())
+			}
+		})
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	input := "Hamburg;12.0\nBulawayo;8.9\n\nPalembang;38.8\nHamburg\nHamburg;-3.4\n"
+	rows, err := countRows(strings.NewReader(input), 16, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 4 {
+		t.Errorf("Wrong row count, expected: 4, got: %d", rows)
+	}
+}
+
+func TestWorkerFlushInterval(t *testing.T) {
+	input := string(benchmarkInput()[:20000])
+
+	expected, err := collectData(strings.NewReader(input), 512, 3, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, flushBytes := range []int{1, 1000, 100000} {
+		got, err := collectData(strings.NewReader(input), 512, 3, &parser{flushBytes: flushBytes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(got.Stats(), expected.Stats()) {
+			t.Errorf("Wrong aggregation when flushing every %d bytes, expected: %v, got: %v", flushBytes, expected.Stats(), got.Stats())
+		}
+	}
+}
+
+func TestDeterministicAssignment(t *testing.T) {
+	inputs := make(chan []byte)
+	workers := make([]chan []byte, 3)
+	for i := range workers {
+		workers[i] = make(chan []byte, 10)
+	}
+	go func() {
+		for i := 0; i < 10; i++ {
+			inputs <- []byte{byte(i)}
+		}
+		close(inputs)
+	}()
+	assignBlocks(inputs, workers)
+
+	for w, ch := range workers {
+		var blocks []byte
+		for b := range ch {
+			blocks = append(blocks, b...)
+		}
+		for i, b := range blocks {
+			if int(b) != w+i*len(workers) {
+				t.Errorf("Wrong blocks for worker %d, got: %v", w, blocks)
+				break
+			}
+		}
+	}
+
+	input := string(benchmarkInput()[:20000])
+	expected, err := collectData(strings.NewReader(input), 512, 3, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := collectData(strings.NewReader(input), 512, 3, &parser{deterministic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got.Stats(), expected.Stats()) {
+		t.Errorf("Wrong deterministic aggregation, expected: %v, got: %v", expected.Stats(), got.Stats())
+	}
+}
+
+func benchmarkInput() []byte {
+	var sb strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%413, i%100-50, i%10)
+	}
+	return []byte(sb.String())
+}
+
+func BenchmarkProcess(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		process(New(), input)
+	}
+}
+
+// BenchmarkProcessDryParse measures the parsing alone, the difference with BenchmarkProcess is the aggregation overhead.
+func BenchmarkProcessDryParse(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		process(&rowCounter{}, input)
+	}
+}
+
+func TestMergeDifferentBuckets(t *testing.T) {
+	input1 := "Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\n"
+	input2 := "Hamburg;-3.4\nİzmir;17.9\nBulawayo;10.1\n"
+
+	expected := New()
+	process(expected, []byte(input1+input2))
+
+	for _, n := range []int{1, 7, 1 << 16} {
+		data := NewBuckets(math.MaxUint16)
+		process(data, []byte(input1))
+		other := NewBuckets(n)
+		process(other, []byte(input2))
+
+		data.Merge(other)
+		if got := data.Stats(); !maps.Equal(got, expected.Stats()) {
+			t.Errorf("Wrong merge with %d buckets, expected: %v, got: %v", n, expected.Stats(), got)
+		}
+		if got := len(data.Flatten()); got != 4 {
+			t.Errorf("Wrong number of stations after merging %d buckets, expected: 4, got: %d", n, got)
+		}
+	}
+}
+
+func TestKeyset10k(t *testing.T) {
+	input := append(benchmarkInput()[:50000], "Hamburg;12.0\nBulawayo;8.9\n"...)
+	expected := New()
+	process(expected, input)
+
+	defer func(n int) { buckets = n }(buckets)
+	if err := useKeyset("10k"); err != nil {
+		t.Fatal(err)
+	}
+	got := New()
+	process(got, input)
+	if !maps.Equal(got.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation with the 10k keyset, expected: %v, got: %v", expected.Stats(), got.Stats())
+	}
+}
+
+func BenchmarkKeyset10k(b *testing.B) {
+	input, err := os.ReadFile("../../../test/resources/samples/measurements-10000-unique-keys.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func(n int) { buckets = n }(buckets)
+
+	for _, ks := range []string{"default", "10k"} {
+		if ks != "default" {
+			if err := useKeyset(ks); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.Run(ks, func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				process(New(), input)
+			}
+		})
+	}
+}
+
+func TestNamehashSpreadsStations(t *testing.T) {
+	var names []string
+	for i := 0; i < 3000; i++ {
+		// Short names, and long names only differing in their first bytes
+		names = append(names, fmt.Sprintf("S%d", i), fmt.Sprintf("%d_Petropavlovsk-Kamchatsky", i))
+	}
+	// Names the old hash of the last bytes mapped to the same bucket
+	names = append(names, "ab", "ba", "Saint-Denis", "Saint-Louis")
+
+	var sb strings.Builder
+	expected := map[string]Stats{}
+	for i, name := range names {
+		for _, temperature := range []int64{int64(i%1000 - 500), int64(i%700 - 200)} {
+			fmt.Fprintf(&sb, "%s;%.1f\n", name, float64(temperature)/10.)
+			s, ok := expected[name]
+			if !ok {
+				s = Stats{Min: temperature, Max: temperature}
+			}
+			s.Min, s.Max = min(s.Min, temperature), max(s.Max, temperature)
+			s.Sum += temperature
+			s.Count++
+			expected[name] = s
+		}
+	}
+
+	data := New()
+	process(data, []byte(sb.String()))
+	if got := data.Stats(); !maps.Equal(got, expected) {
+		t.Errorf("Wrong aggregation of %d stations, got %d stations", len(expected), len(got))
+	}
+
+	longest := 0
+	for _, b := range data {
+		if b != nil {
+			longest = max(longest, len(b.data))
+		}
+	}
+	if longest > maxBucketScan {
+		t.Errorf("Expected at most %d stations per bucket, got: %d", maxBucketScan, longest)
+	}
+}
+
+func TestBucketCollisions(t *testing.T) {
+	// With a single bucket every station collides, so the bucket switches to its name index
+	data := NewBuckets(1)
+	other := NewBuckets(1)
+	for i := 0; i < 100; i++ {
+		data.Add([]byte(fmt.Sprintf("Station%d", i)), int64(i))
+		other.Add([]byte(fmt.Sprintf("Station%d", i)), int64(-i))
+	}
+	data.Merge(other)
+
+	if data[0].index == nil {
+		t.Errorf("Expected the bucket to index its %d stations", len(data[0].data))
+	}
+	stats := data.Stats()
+	if len(stats) != 100 {
+		t.Fatalf("Wrong number of stations, expected: 100, got: %d", len(stats))
+	}
+	if got, expected := stats["Station42"], (Stats{Min: -42, Max: 42, Sum: 0, Count: 2}); got != expected {
+		t.Errorf("Wrong aggregation of Station42, expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestInsertionOrder(t *testing.T) {
+	var sb strings.Builder
+	var expected []string
+	seen := map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		// Stations keep appearing for the first time in every block, not in the order of their names
+		name := fmt.Sprintf("Station%d", (i*7919)%(i/3+1))
+		if !seen[name] {
+			seen[name] = true
+			expected = append(expected, name)
+		}
+		fmt.Fprintf(&sb, "%s;%d.%d\n", name, i%50-25, i%10)
+	}
+
+	for _, p := range []*parser{{insertionOrder: true}, {insertionOrder: true, flushBytes: 1000}, {insertionOrder: true, lenient: true}} {
+		data, err := collectData(strings.NewReader(sb.String()), 512, 3, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := data.Flatten()
+		sortBySeq(results)
+
+		var got []string
+		for _, m := range results {
+			got = append(got, string(m.name))
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("Wrong insertion order with %+v, expected: %v, got: %v", *p, expected, got)
+		}
+	}
+}
+
+func TestStandardErrorOfMean(t *testing.T) {
+	readings := map[string][]float64{
+		"Hamburg":  {12.0, -3.4, 7.7, 0.1, 15.2},
+		"Bulawayo": {8.9, 10.1},
+		"Abha":     {18.3},
+	}
+	var sb strings.Builder
+	for name, temps := range readings {
+		for _, temp := range temps {
+			fmt.Fprintf(&sb, "%s;%.1f\n", name, temp)
+		}
+	}
+	data := New()
+	process(data, []byte(sb.String()))
+
+	for _, m := range data.Flatten() {
+		temps := readings[string(m.name)]
+		var mean, variance float64
+		for _, temp := range temps {
+			mean += temp / float64(len(temps))
+		}
+		for _, temp := range temps {
+			variance += (temp - mean) * (temp - mean) / float64(len(temps))
+		}
+		expected := math.Sqrt(variance) / math.Sqrt(float64(len(temps)))
+		if got := m.sem(); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("Wrong standard error of the mean for %s, expected: %v, got: %v", m.name, expected, got)
+		}
+	}
+}
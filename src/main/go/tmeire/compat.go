@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// printJava prints the results like the reference Java implementation, which prints a TreeMap of the
+// results: {name=min/mean/max, name=min/mean/max}, without a separator after the last station.
+// Every value is rounded with round(v) = Math.round(v * 10.0) / 10.0 (see javaRound).
+func printJava(w io.Writer, results []*measurement, eol string) {
+	fmt.Fprint(w, "{")
+	for i, m := range results {
+		if i > 0 {
+			fmt.Fprint(w, ", ")
+		}
+		fmt.Fprintf(w, "%s=%.1f/%.1f/%.1f", m.name,
+			float64(m.min)/10., javaRound(float64(m.sum)/float64(m.count))/10., float64(m.max)/10.)
+	}
+	fmt.Fprint(w, "}"+eol)
+}
+
+// javaRound rounds like Java's Math.round, which is floor(v + 0.5): halves are rounded up instead of away
+// from zero as by math.Round, so -2.5 rounds to -2. Math.round returns a long, so values rounding to zero
+// become 0 and never -0, which would otherwise be printed as -0.0.
+func javaRound(v float64) float64 {
+	return math.Floor(v+0.5) + 0
+}
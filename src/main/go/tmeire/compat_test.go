@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintJava(t *testing.T) {
+	results := []*measurement{
+		// A mean of -0.25 rounds to -0.3 with math.Round, but to -0.2 in Java
+		{name: []byte("Bulawayo"), min: -30, max: 25, sum: -5, count: 2},
+		// A mean of -0.033 rounds to -0.0 with math.Round, but to 0.0 in Java
+		{name: []byte("Hamburg"), min: -34, max: 33, sum: -1, count: 3},
+		// A mean of 0.25 rounds to 0.3 with both
+		{name: []byte("Palembang"), min: 0, max: 5, sum: 5, count: 2},
+	}
+
+	var sb strings.Builder
+	printJava(&sb, results, "\n")
+	expected := "{Bulawayo=-3.0/-0.2/2.5, Hamburg=-3.4/0.0/3.3, Palembang=0.0/0.3/0.5}\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong java output, expected: %q, got: %q", expected, sb.String())
+	}
+
+	sb.Reset()
+	printMeasurements(&sb, results, precision{min: 1, mean: 1, max: 1}, false, "\n")
+	if !strings.Contains(sb.String(), "Bulawayo=-3.0/-0.3/2.5") || !strings.Contains(sb.String(), "Hamburg=-3.4/-0.0/3.3") {
+		t.Errorf("Expected the default output to round away from zero, got: %q", sb.String())
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns a reader over the decompressed data if r starts with a gzip header, or over r itself otherwise.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, 1024*1024)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return fillReader{zr}, nil
+}
+
+// fillReader fills the whole buffer on every read unless r fails, as a gzip reader only hands out a little
+// at a time and sendBlocks starts a new block after every read. Unlike fullReader it keeps the errors of r.
+type fillReader struct {
+	r io.Reader
+}
+
+func (f fillReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := f.r.Read(b[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// startsWithGzip reports whether r starts with a gzip header.
+func startsWithGzip(r io.ReaderAt) (bool, error) {
+	magic := make([]byte, len(gzipMagic))
+	n, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return bytes.Equal(magic[:n], gzipMagic), nil
+}
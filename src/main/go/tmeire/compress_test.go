@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestDecompressTruncated(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		input.WriteString("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\n")
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(input.Bytes())
+	zw.Close()
+
+	r, err := decompress(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := collectData(r, 1024, 2, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := New()
+	process(expected, input.Bytes())
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+
+	r, err = decompress(bytes.NewReader(compressed.Bytes()[:compressed.Len()/2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = collectData(r, 1024, 2, &parser{})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected a truncated stream error, got: %v", err)
+	}
+
+	// The results from before the truncation only contain full records
+	var rows int64
+	for name, s := range data.Stats() {
+		if s.Min != expected.Stats()[name].Min || s.Max != expected.Stats()[name].Max {
+			t.Errorf("Wrong partial aggregation of %s: %v", name, s)
+		}
+		rows += s.Count
+	}
+	if rows == 0 || rows >= 6000 {
+		t.Errorf("Expected a part of the rows to be aggregated, got %d", rows)
+	}
+
+	// The error tells the range of records read before the truncation
+	records := []string{"Hamburg;12.0", "Bulawayo;8.9", "Palembang;38.8"}
+	completed := fmt.Sprintf("the records from %q to %q are complete", records[0], records[(rows-1)%3])
+	if !strings.Contains(err.Error(), completed) {
+		t.Errorf("Expected the error to say %s, got: %v", completed, err)
+	}
+}
+
+func TestDecompressPlain(t *testing.T) {
+	r, err := decompress(bytes.NewReader([]byte("Hamburg;12.0\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil || string(b) != "Hamburg;12.0\n" {
+		t.Errorf("Expected the plain input back, got: %q, %v", b, err)
+	}
+}
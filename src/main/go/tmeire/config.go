@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// configFile is the name of the file with default flag values, looked up in the working directory
+// and then in the home directory.
+const configFile = ".calcrc"
+
+// findConfig returns the path of the config file to load, or "" if there is none.
+func findConfig() string {
+	paths := []string{configFile}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, configFile))
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// applyConfig sets the flags in the JSON object of the config file at path, like {"format": "csv", "gomaxprocs": 4},
+// that weren't set on the command line.
+func applyConfig(fset *flag.FlagSet, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	set := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, raw := range values {
+		if fset.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %q", path, name)
+		}
+		if set[name] {
+			continue
+		}
+
+		// Strings are set unquoted, numbers and booleans as written
+		value := string(bytes.TrimSpace(raw))
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+		if err := fset.Set(name, value); err != nil {
+			return fmt.Errorf("%s: flag %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(path, []byte(`{"format": "csv", "gomaxprocs": 4, "partial-ok": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := flag.NewFlagSet("calc", flag.ContinueOnError)
+	format := fset.String("format", "text", "")
+	gomaxprocs := fset.Int("gomaxprocs", 0, "")
+	partialOK := fset.Bool("partial-ok", false, "")
+	if err := fset.Parse([]string{"-format", "ndjson"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(fset, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if *format != "ndjson" {
+		t.Errorf("Wrong format, the command line should override the config, expected: ndjson, got: %s", *format)
+	}
+	if *gomaxprocs != 4 || !*partialOK {
+		t.Errorf("Wrong config values, expected: 4 true, got: %d %v", *gomaxprocs, *partialOK)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"workers": 4}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(fset, path); err == nil {
+		t.Errorf("Expected an error for an unknown flag")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// countMismatchExitCode is the exit status when the row counts don't match --expect-counts.
+const countMismatchExitCode = 5
+
+// loadExpectedCounts reads the station,count lines of an --expect-counts manifest, optionally starting with
+// a header line. The count follows the last comma, so station names can have commas.
+func loadExpectedCounts(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts := map[string]int64{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		idx := strings.LastIndexByte(text, ',')
+		if idx < 0 {
+			return nil, fmt.Errorf("%s:%d: expected station,count, got %q", path, line, text)
+		}
+		count, err := strconv.ParseInt(text[idx+1:], 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid count: %w", path, line, err)
+		}
+		counts[text[:idx]] = count
+	}
+	return counts, scanner.Err()
+}
+
+// checkCounts reports every station of results whose count isn't the expected one to w, as well as the
+// expected stations without any rows, and returns the number of mismatches.
+func checkCounts(results []*measurement, expected map[string]int64, w io.Writer) int {
+	mismatches := 0
+	seen := make(map[string]bool, len(results))
+	for _, m := range results {
+		name := string(m.name)
+		seen[name] = true
+		if want, ok := expected[name]; !ok {
+			fmt.Fprintf(w, "count mismatch: station %s has %d rows, but isn't expected\n", name, m.count)
+			mismatches++
+		} else if m.count != want {
+			fmt.Fprintf(w, "count mismatch: station %s has %d rows, expected %d\n", name, m.count, want)
+			mismatches++
+		}
+	}
+
+	var missing []string
+	for name := range expected {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	for _, name := range missing {
+		fmt.Fprintf(w, "count mismatch: station %s has no rows, expected %d\n", name, expected[name])
+		mismatches++
+	}
+	return mismatches
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpectCounts(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "counts.csv")
+	if err := os.WriteFile(manifest, []byte("station,count\nHamburg,3\nBulawayo,2\r\nSt. John's, NL,1\nAbha,1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := loadExpectedCounts(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 4 || expected["St. John's, NL"] != 1 {
+		t.Fatalf("Wrong expected counts, got: %v", expected)
+	}
+
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nSt. John's, NL;1.0\nBulawayo;1.0\nHamburg;34.2\nAbha;1.0\n"))
+	var sb strings.Builder
+	if n := checkCounts(sortedResults(data), expected, &sb); n != 0 || sb.Len() != 0 {
+		t.Errorf("Expected the counts to match, got %d mismatches: %q", n, sb.String())
+	}
+
+	// Hamburg is one row short, Abha is missing and Palembang isn't expected
+	data = New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nSt. John's, NL;1.0\nBulawayo;1.0\nPalembang;38.8\n"))
+	sb.Reset()
+	report := "count mismatch: station Hamburg has 2 rows, expected 3\n" +
+		"count mismatch: station Palembang has 1 rows, but isn't expected\n" +
+		"count mismatch: station Abha has no rows, expected 1\n"
+	if n := checkCounts(sortedResults(data), expected, &sb); n != 3 || sb.String() != report {
+		t.Errorf("Wrong count mismatches, expected 3: %q, got %d: %q", report, n, sb.String())
+	}
+
+	if err := os.WriteFile(manifest, []byte("Hamburg,3\nBulawayo,two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadExpectedCounts(manifest); err == nil {
+		t.Error("Expected an error for an invalid count")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// loadKey reads an AES key of 16, 24 or 32 bytes from a file, either as the raw bytes or hex encoded.
+func loadKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := hex.DecodeString(string(bytes.TrimSpace(b))); err == nil {
+		b = key
+	}
+	if _, err := aes.NewCipher(b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// decrypt returns a reader decrypting r, which is encrypted with AES in CTR mode and starts with the IV.
+func decrypt(r io.Reader, key []byte) (io.Reader, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, block.BlockSize())
+	if _, err := io.ReadFull(r, iv); err != nil {
+		return nil, fmt.Errorf("reading the IV: %w", err)
+	}
+	return cipher.StreamReader{S: cipher.NewCTR(block, iv), R: r}, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecrypt(t *testing.T) {
+	plaintext := []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\n")
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(key)
+	rand.Read(iv)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := append([]byte{}, iv...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, plaintext)
+	encrypted = append(encrypted, ciphertext...)
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := New()
+	process(expected, plaintext)
+	data, err := aggregate(bytes.NewReader(encrypted), 16, &parser{decryptKey: loaded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation of the decrypted input, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+
+	if err := os.WriteFile(keyFile, []byte("too short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKey(keyFile); err == nil {
+		t.Errorf("Expected an error for an invalid key size")
+	}
+}
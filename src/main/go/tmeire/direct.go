@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// directIO reads regular input files with O_DIRECT, bypassing the page cache, set by --direct-io.
+var directIO = false
+
+const (
+	// directAlign is the alignment of the buffers, offsets and lengths of O_DIRECT reads,
+	// the largest logical block size of common disks.
+	directAlign = 4096
+
+	// directChunk is the size of every O_DIRECT read.
+	directChunk = 4 << 20
+)
+
+// directInput returns file, reading it with O_DIRECT through a directReader with --direct-io.
+// If the OS or file system doesn't support O_DIRECT, it warns and reads through the page cache.
+func directInput(file *os.File) io.Reader {
+	if !directIO {
+		return file
+	}
+	if err := enableDirectIO(file); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: direct io is not supported for %s, reading through the page cache: %v\n", file.Name(), err)
+		return file
+	}
+	return newDirectReader(file)
+}
+
+// directReader reads r in aligned chunks of directChunk bytes into an aligned buffer,
+// and copies them out to the callers, whatever their buffers.
+type directReader struct {
+	r   io.Reader
+	buf []byte
+	// data is the part of buf not read yet.
+	data []byte
+	err  error
+}
+
+func newDirectReader(r io.Reader) *directReader {
+	return &directReader{r: r, buf: alignedBuffer(directChunk, directAlign)}
+}
+
+func (d *directReader) Read(b []byte) (int, error) {
+	if len(d.data) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		var n int
+		n, d.err = d.r.Read(d.buf)
+		// Only the end of the file reads short, and reading on from the unaligned offset after it would fail
+		if n < len(d.buf) && d.err == nil {
+			d.err = io.EOF
+		}
+		d.data = d.buf[:n]
+		if n == 0 {
+			return 0, d.err
+		}
+	}
+	n := copy(b, d.data)
+	d.data = d.data[n:]
+	return n, nil
+}
+
+// alignedBuffer returns a buffer of size bytes starting at a multiple of align.
+func alignedBuffer(size, align int) []byte {
+	b := make([]byte, size+align)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) % uintptr(align)); rem != 0 {
+		offset = align - rem
+	}
+	return b[offset : offset+size : offset+size]
+}
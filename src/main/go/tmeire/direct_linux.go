@@ -0,0 +1,31 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableDirectIO sets O_DIRECT on the open file. File systems without direct io, like tmpfs, refuse it.
+func enableDirectIO(file *os.File) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = conn.Control(func(fd uintptr) {
+		flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+		if errno != 0 {
+			serr = errno
+			return
+		}
+		if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_SETFL, flags|syscall.O_DIRECT); errno != 0 {
+			serr = errno
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+// alignmentChecker fails every read with a buffer, length or offset that O_DIRECT wouldn't accept.
+type alignmentChecker struct {
+	r      io.Reader
+	offset int
+}
+
+func (c *alignmentChecker) Read(b []byte) (int, error) {
+	if addr := uintptr(unsafe.Pointer(&b[0])); addr%directAlign != 0 || len(b)%directAlign != 0 || c.offset%directAlign != 0 {
+		return 0, fmt.Errorf("unaligned read of %d bytes at %x, offset %d", len(b), addr, c.offset)
+	}
+	n, err := c.r.Read(b)
+	c.offset += n
+	return n, err
+}
+
+func TestDirectIO(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; input.Len() < 3*directChunk+1234; i++ {
+		fmt.Fprintf(&input, "Station %d;%d.%d\n", i%997, i%50-20, i%10)
+	}
+	expected := New()
+	process(expected, input.Bytes())
+
+	// Every read is aligned, whatever the reads of the caller
+	data, err := collectData(newDirectReader(&alignmentChecker{r: bytes.NewReader(input.Bytes())}), 100_000, 2, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation of the aligned reads")
+	}
+
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, input.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := enableDirectIO(file); err != nil {
+		t.Skipf("The file system of the temp dir doesn't support O_DIRECT: %v", err)
+	}
+
+	data, err = collectData(newDirectReader(file), 100_000, 2, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation of the file read with O_DIRECT")
+	}
+}
+
+func TestDirectIOKeepsTheSerialPath(t *testing.T) {
+	defer func(direct bool, decide func(int64) bool) { directIO, useSerial = direct, decide }(directIO, useSerial)
+	directIO = true
+	serial := 0
+	useSerial = func(size int64) bool {
+		serial++
+		return true
+	}
+
+	input := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	probe, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enableDirectIO(probe)
+	probe.Close()
+	if err != nil {
+		t.Skipf("The file system of the temp dir doesn't support O_DIRECT: %v", err)
+	}
+
+	data, err := aggregateFile(filename, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != 1 {
+		t.Errorf("Expected the small file to take the serial path with O_DIRECT")
+	}
+	expected := New()
+	process(expected, []byte(input))
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+}
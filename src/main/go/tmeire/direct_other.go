@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func enableDirectIO(file *os.File) error {
+	return errors.New("O_DIRECT is only supported on linux")
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// A binary dump starts with the dumpMagic bytes, a uint32 schema version and a uint64 record count,
+// followed by one record per measurement: a uint16 name length, the name bytes and the min, max, sum,
+// count and sum of squares as int64. All integers are little endian.
+const (
+	dumpMagic      = "1BRC"
+	dumpVersion    = 2
+	dumpHeaderSize = len(dumpMagic) + 4 + 8
+	dumpFieldsSize = 5 * 8
+)
+
+// maxDumpName is the longest station name in bytes a dump can store, as it stores the length in a uint16.
+const maxDumpName = math.MaxUint16
+
+// checkDumpNames returns an error for the first station of results with a name longer than maxDumpName.
+func checkDumpNames(results []*measurement) error {
+	for _, m := range results {
+		if len(m.name) > maxDumpName {
+			return fmt.Errorf("station name of %d bytes starting with %q is too long for a dump, at most %d bytes fit", len(m.name), m.name[:32], maxDumpName)
+		}
+	}
+	return nil
+}
+
+func appendDumpHeader(b []byte, n int) []byte {
+	b = append(b, dumpMagic...)
+	b = binary.LittleEndian.AppendUint32(b, dumpVersion)
+	return binary.LittleEndian.AppendUint64(b, uint64(n))
+}
+
+func dumpSize(results []*measurement) int {
+	size := dumpHeaderSize
+	for _, m := range results {
+		size += 2 + len(m.name) + dumpFieldsSize
+	}
+	return size
+}
+
+// encodeDump writes the dump for results into b, which must be at least dumpSize(results) long.
+// The names must pass checkDumpNames.
+func encodeDump(b []byte, results []*measurement) {
+	off := len(appendDumpHeader(b[:0], len(results)))
+	for _, m := range results {
+		binary.LittleEndian.PutUint16(b[off:], uint16(len(m.name)))
+		off += 2
+		off += copy(b[off:], m.name)
+		for _, v := range [...]int64{m.min, m.max, m.sum, m.count, m.sumSq} {
+			binary.LittleEndian.PutUint64(b[off:], uint64(v))
+			off += 8
+		}
+	}
+}
+
+// writeDump writes the dump through a buffered writer, encoding one record at a time.
+func writeDump(w io.Writer, results []*measurement) error {
+	if err := checkDumpNames(results); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+
+	rec := make([]byte, 0, 2+100+dumpFieldsSize)
+	rec = appendDumpHeader(rec, len(results))
+	if _, err := bw.Write(rec); err != nil {
+		return err
+	}
+	for _, m := range results {
+		rec = encodeRecord(rec[:0], m)
+		if _, err := bw.Write(rec); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func encodeRecord(b []byte, m *measurement) []byte {
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(m.name)))
+	b = append(b, m.name...)
+	for _, v := range [...]int64{m.min, m.max, m.sum, m.count, m.sumSq} {
+		b = binary.LittleEndian.AppendUint64(b, uint64(v))
+	}
+	return b
+}
+
+var (
+	_ encoding.BinaryMarshaler   = measurements(nil)
+	_ encoding.BinaryUnmarshaler = (*measurements)(nil)
+)
+
+// MarshalBinary returns the binary dump of m, in the order of its buckets rather than by name.
+// The dump is encoded straight into a single buffer of its exact size, so it's the only allocation.
+func (m measurements) MarshalBinary() ([]byte, error) {
+	n, size := 0, dumpHeaderSize
+	for _, b := range m {
+		if b == nil {
+			continue
+		}
+		if err := checkDumpNames(b.data); err != nil {
+			return nil, err
+		}
+		for _, mm := range b.data {
+			n++
+			size += 2 + len(mm.name) + dumpFieldsSize
+		}
+	}
+
+	buf := appendDumpHeader(make([]byte, 0, size), n)
+	for _, b := range m {
+		if b == nil {
+			continue
+		}
+		for _, mm := range b.data {
+			buf = encodeRecord(buf, mm)
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces m with the measurements in the binary dump b, rejecting invalid entries like decodeDump.
+// The names point into a copy of b.
+func (m *measurements) UnmarshalBinary(b []byte) error {
+	results, err := decodeDump(bytes.Clone(b), false)
+	if err != nil {
+		return err
+	}
+	data := New()
+	for _, mm := range results {
+		data.AddMeasurement(mm)
+	}
+	*m = data
+	return nil
+}
+
+func writeBufferedDumpFile(path string, results []*measurement) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeDump(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var (
+	errShortDump = errors.New("dump is truncated")
+	errNotDump   = errors.New("not a dump, the magic number is missing")
+)
+
+// decodeDump decodes the measurements in b. The names point into b.
+// Entries with a min above their max or without readings are rejected, unless repair is set:
+// then the min and max are swapped and entries without readings are dropped.
+func decodeDump(b []byte, repair bool) ([]*measurement, error) {
+	results, _, err := decodeDumpPrefix(b, repair)
+	return results, err
+}
+
+// decodeDumpPrefix is decodeDump for a dump at the start of b, it also returns the bytes after the dump.
+func decodeDumpPrefix(b []byte, repair bool) ([]*measurement, []byte, error) {
+	if len(b) < dumpHeaderSize {
+		return nil, nil, errShortDump
+	}
+	if string(b[:len(dumpMagic)]) != dumpMagic {
+		return nil, nil, errNotDump
+	}
+	if v := binary.LittleEndian.Uint32(b[len(dumpMagic):]); v != dumpVersion {
+		return nil, nil, fmt.Errorf("dump has schema version %d, only version %d is supported", v, dumpVersion)
+	}
+	n := binary.LittleEndian.Uint64(b[len(dumpMagic)+4:])
+	b = b[dumpHeaderSize:]
+
+	var results []*measurement
+	for i := uint64(0); i < n; i++ {
+		if len(b) < 2 {
+			return nil, nil, errShortDump
+		}
+		l := int(binary.LittleEndian.Uint16(b))
+		if len(b) < 2+l+dumpFieldsSize {
+			return nil, nil, errShortDump
+		}
+		m := &measurement{name: b[2 : 2+l]}
+		b = b[2+l:]
+		for _, v := range [...]*int64{&m.min, &m.max, &m.sum, &m.count, &m.sumSq} {
+			*v = int64(binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		}
+
+		if m.count <= 0 {
+			if !repair {
+				return nil, nil, fmt.Errorf("dump entry %d (%s) has %d readings", i, m.name, m.count)
+			}
+			continue
+		}
+		if m.min > m.max {
+			if !repair {
+				return nil, nil, fmt.Errorf("dump entry %d (%s) has min %d above max %d", i, m.name, m.min, m.max)
+			}
+			m.min, m.max = m.max, m.min
+		}
+		results = append(results, m)
+	}
+	return results, b, nil
+}
+
+func loadDumpFile(path string, repair bool) ([]*measurement, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	results, err := decodeDump(b, repair)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+// mergeDumps adds the measurements of the dump files to data. Entries failing checkRounding are merged,
+// but reported to warn. With weights, one per path, it also tracks the weightedMean of every station.
+func mergeDumps(data measurements, paths []string, weights []float64, repair bool, tolerance int64, warn io.Writer) error {
+	for i, path := range paths {
+		results, err := loadDumpFile(path, repair)
+		if err != nil {
+			return err
+		}
+		for _, m := range results {
+			if err := checkRounding(m, tolerance); err != nil {
+				fmt.Fprintf(warn, "warning: %s: %v\n", path, err)
+			}
+			if weights != nil {
+				m.weighted = &weightedMean{sum: weights[i] * float64(m.sum) / float64(m.count) / 10., weight: weights[i]}
+			}
+			data.AddMeasurement(m)
+		}
+	}
+	return nil
+}
+
+// checkRounding returns an error if the sum of m can't be the sum of its readings between its min and max, give
+// or take tolerance tenths per reading. As dumps store exact tenths that only happens if they were rounded after
+// aggregating, like a min and max rounded to whole degrees.
+func checkRounding(m *measurement, tolerance int64) error {
+	if m.sum < (m.min-tolerance)*m.count || m.sum > (m.max+tolerance)*m.count {
+		return fmt.Errorf("station %s has a sum of %d over %d readings, which is impossible between its min %d and max %d",
+			m.name, m.sum, m.count, m.min, m.max)
+	}
+	return nil
+}
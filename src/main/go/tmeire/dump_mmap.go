@@ -0,0 +1,38 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// writeDumpFile pre-sizes the dump file and encodes the results straight into a shared mapping of it.
+func writeDumpFile(path string, results []*measurement) error {
+	if err := checkDumpNames(results); err != nil {
+		return err
+	}
+	size := dumpSize(results)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		return err
+	}
+
+	b, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	encodeDump(b, results)
+	if err := syscall.Munmap(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
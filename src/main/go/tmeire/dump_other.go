@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+func writeDumpFile(path string, results []*measurement) error {
+	return writeBufferedDumpFile(path, results)
+}
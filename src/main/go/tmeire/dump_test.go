@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDumpFileMatchesBufferedDump(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\nİzmir;17.9\n"))
+	results := sortedResults(data)
+
+	dir := t.TempDir()
+	mapped := filepath.Join(dir, "mapped.bin")
+	buffered := filepath.Join(dir, "buffered.bin")
+	if err := writeDumpFile(mapped, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBufferedDumpFile(buffered, results); err != nil {
+		t.Fatal(err)
+	}
+
+	b1, err := os.ReadFile(mapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := os.ReadFile(buffered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b1) != dumpSize(results) {
+		t.Errorf("Wrong dump size, expected: %d, got: %d", dumpSize(results), len(b1))
+	}
+	if !bytes.Equal(b1, b2) {
+		t.Errorf("Mapped dump differs from buffered dump:\n%x\n%x", b1, b2)
+	}
+}
+
+func TestDecodeDump(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\n"))
+	results := sortedResults(data)
+
+	b := make([]byte, dumpSize(results))
+	encodeDump(b, results)
+
+	decoded, err := decodeDump(b, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := New()
+	for _, m := range decoded {
+		loaded.AddMeasurement(m)
+	}
+	if !maps.Equal(loaded.Stats(), data.Stats()) {
+		t.Errorf("Wrong decoded dump, expected: %v, got: %v", data.Stats(), loaded.Stats())
+	}
+	for i, m := range decoded {
+		if m.sumSq != results[i].sumSq {
+			t.Errorf("Wrong decoded sum of squares for %s, expected: %d, got: %d", m.name, results[i].sumSq, m.sumSq)
+		}
+	}
+
+	if _, err := decodeDump(b[:len(b)-1], false); !errors.Is(err, errShortDump) {
+		t.Errorf("Expected a truncated dump error, got: %v", err)
+	}
+}
+
+func TestDecodeCorruptDump(t *testing.T) {
+	swapped := &measurement{name: []byte("Hamburg"), min: 342, max: -34, sum: 308, count: 2}
+	empty := &measurement{name: []byte("Bulawayo"), min: 89, max: 89, sum: 0, count: 0}
+	valid := &measurement{name: []byte("Palembang"), min: 388, max: 388, sum: 388, count: 1}
+
+	for _, tc := range []struct {
+		name    string
+		entries []*measurement
+		err     string
+	}{
+		{name: "min above max", entries: []*measurement{valid, swapped}, err: "dump entry 1 (Hamburg) has min 342 above max -34"},
+		{name: "no readings", entries: []*measurement{empty, valid}, err: "dump entry 0 (Bulawayo) has 0 readings"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := make([]byte, dumpSize(tc.entries))
+			encodeDump(b, tc.entries)
+
+			if _, err := decodeDump(b, false); err == nil || err.Error() != tc.err {
+				t.Errorf("Wrong validation error, expected: %s, got: %v", tc.err, err)
+			}
+		})
+	}
+
+	b := make([]byte, dumpSize([]*measurement{swapped, empty, valid}))
+	encodeDump(b, []*measurement{swapped, empty, valid})
+	repaired, err := decodeDump(b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repaired) != 2 || repaired[0].min != -34 || repaired[0].max != 342 || string(repaired[1].name) != "Palembang" {
+		t.Errorf("Wrong repaired dump: %+v, %+v", repaired[0], repaired[1:])
+	}
+}
+
+func TestDecodeDumpVersion(t *testing.T) {
+	results := []*measurement{{name: []byte("Hamburg"), min: 120, max: 120, sum: 120, count: 1}}
+	b := make([]byte, dumpSize(results))
+	encodeDump(b, results)
+
+	binary.LittleEndian.PutUint32(b[len(dumpMagic):], dumpVersion+1)
+	expected := fmt.Sprintf("dump has schema version %d, only version %d is supported", dumpVersion+1, dumpVersion)
+	if _, err := decodeDump(b, false); err == nil || err.Error() != expected {
+		t.Errorf("Wrong version error, expected: %s, got: %v", expected, err)
+	}
+
+	// A dump from before the header was versioned starts with its record count
+	old := binary.LittleEndian.AppendUint64(nil, 1)
+	old = encodeRecord(old, results[0])
+	if _, err := decodeDump(old, false); !errors.Is(err, errNotDump) {
+		t.Errorf("Expected a missing magic number error, got: %v", err)
+	}
+}
+
+func TestMergeDumpsRoundingWarning(t *testing.T) {
+	dir := t.TempDir()
+	exact := filepath.Join(dir, "exact.bin")
+	rounded := filepath.Join(dir, "rounded.bin")
+
+	// Readings of 12.3 and 12.4 with the min and max rounded to whole degrees after aggregating
+	if err := writeBufferedDumpFile(exact, []*measurement{{name: []byte("Hamburg"), min: 123, max: 124, sum: 247, count: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBufferedDumpFile(rounded, []*measurement{{name: []byte("Hamburg"), min: 120, max: 120, sum: 247, count: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings strings.Builder
+	data := New()
+	if err := mergeDumps(data, []string{exact, rounded}, nil, false, 0, &warnings); err != nil {
+		t.Fatal(err)
+	}
+	expected := "warning: " + rounded + ": station Hamburg has a sum of 247 over 2 readings, which is impossible between its min 120 and max 120\n"
+	if warnings.String() != expected {
+		t.Errorf("Wrong warnings, expected: %q, got: %q", expected, warnings.String())
+	}
+	if got := data.Stats()["Hamburg"]; got.Count != 4 || got.Sum != 494 {
+		t.Errorf("Expected both entries to be merged, got: %v", got)
+	}
+
+	warnings.Reset()
+	if err := mergeDumps(New(), []string{exact, rounded}, nil, false, 5, &warnings); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.Len() != 0 {
+		t.Errorf("Expected no warnings within the tolerance, got: %q", warnings.String())
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\nİzmir;17.9\n"))
+
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != cap(b) || len(b) != dumpSize(data.Flatten()) {
+		t.Errorf("Expected a buffer of exactly the dump size %d, got: %d bytes with a capacity of %d", dumpSize(data.Flatten()), len(b), cap(b))
+	}
+	if allocs := testing.AllocsPerRun(10, func() { data.MarshalBinary() }); allocs != 1 {
+		t.Errorf("Expected a single allocation for the buffer, got: %v", allocs)
+	}
+
+	var got measurements
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	// The names don't point into the marshaled buffer
+	clear(b)
+	if !maps.Equal(got.Stats(), data.Stats()) {
+		t.Errorf("Wrong round trip, expected: %v, got: %v", data.Stats(), got.Stats())
+	}
+
+	if err := got.UnmarshalBinary(b[:dumpHeaderSize-1]); !errors.Is(err, errShortDump) {
+		t.Errorf("Expected a truncated dump error, got: %v", err)
+	}
+}
+
+func TestDumpLongName(t *testing.T) {
+	results := []*measurement{
+		{name: []byte("Hamburg"), min: 120, max: 120, sum: 120, count: 1},
+		{name: bytes.Repeat([]byte("a"), maxDumpName+1), min: 89, max: 89, sum: 89, count: 1},
+	}
+	dir := t.TempDir()
+	for name, write := range map[string]func(string, []*measurement) error{"mapped": writeDumpFile, "buffered": writeBufferedDumpFile} {
+		if err := write(filepath.Join(dir, name+".bin"), results); err == nil || !strings.Contains(err.Error(), "too long for a dump") {
+			t.Errorf("Expected a %s dump to reject the long name, got: %v", name, err)
+		}
+	}
+
+	data := New()
+	for _, m := range results {
+		data.AddMeasurement(m)
+	}
+	if _, err := data.MarshalBinary(); err == nil || !strings.Contains(err.Error(), "too long for a dump") {
+		t.Errorf("Expected MarshalBinary to reject the long name, got: %v", err)
+	}
+}
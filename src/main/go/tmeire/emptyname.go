@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// emptyNamePolicy is what to do with lines with a delimiter but no station name.
+type emptyNamePolicy int
+
+const (
+	emptyNameSkip emptyNamePolicy = iota
+	emptyNameKeep
+	emptyNameError
+)
+
+func parseEmptyNamePolicy(s string) (emptyNamePolicy, error) {
+	switch s {
+	case "skip":
+		return emptyNameSkip, nil
+	case "keep":
+		return emptyNameKeep, nil
+	case "error":
+		return emptyNameError, nil
+	}
+	return 0, fmt.Errorf("unknown empty name policy %q, expected skip, keep or error", s)
+}
+
+// emptyNameChecker fails reading on the first line starting with the delimiter, for --empty-name=error.
+type emptyNameChecker struct {
+	r    io.Reader
+	line int
+	// midLine is set when the last byte read didn't end a line.
+	midLine bool
+}
+
+func (c *emptyNameChecker) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	for _, ch := range b[:n] {
+		if !c.midLine {
+			c.line++
+			if ch == ';' {
+				return n, fmt.Errorf("line %d has an empty station name", c.line)
+			}
+		}
+		c.midLine = ch != '\n'
+	}
+	return n, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEmptyNamePolicy(t *testing.T) {
+	input := "Hamburg;12.0\n;12.3\nBulawayo;8.9\n\n;-1.5\n"
+	for _, tc := range []struct {
+		policy   string
+		expected map[string]Stats
+		err      string
+	}{
+		{policy: "skip", expected: map[string]Stats{
+			"Hamburg":  {Min: 120, Max: 120, Sum: 120, Count: 1},
+			"Bulawayo": {Min: 89, Max: 89, Sum: 89, Count: 1},
+		}},
+		{policy: "keep", expected: map[string]Stats{
+			"Hamburg":  {Min: 120, Max: 120, Sum: 120, Count: 1},
+			"Bulawayo": {Min: 89, Max: 89, Sum: 89, Count: 1},
+			"":         {Min: -15, Max: 123, Sum: 108, Count: 2},
+		}},
+		{policy: "error", err: "line 2 has an empty station name"},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			policy, err := parseEmptyNamePolicy(tc.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := aggregate(iotest.OneByteReader(strings.NewReader(input)), 16, &parser{emptyName: policy})
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("Wrong error, expected: %s, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := data.Stats(); !maps.Equal(got, tc.expected) {
+				t.Errorf("Wrong aggregation, expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+
+	if _, err := parseEmptyNamePolicy("drop"); err == nil {
+		t.Errorf("Expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// fingerprint returns the FNV-1a hash of the station name and its min, max, sum and count.
+func (m *measurement) fingerprint() uint64 {
+	h := fnv.New64a()
+	h.Write(m.name)
+	var b [8]byte
+	for _, v := range [...]int64{m.min, m.max, m.sum, m.count} {
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		h.Write(b[:])
+	}
+	return h.Sum64()
+}
+
+// writeFingerprints writes the fingerprint of every station in the sorted results, followed by the fingerprint
+// of the whole dataset: the hash of the station fingerprints in order. As the results only hold the aggregates,
+// datasets with the same rows in a different order have the same fingerprints.
+func writeFingerprints(w io.Writer, results []*measurement, eol string) error {
+	bw := bufio.NewWriter(w)
+	dataset := fnv.New64a()
+	var b [8]byte
+	for _, m := range results {
+		fp := m.fingerprint()
+		fmt.Fprintf(bw, "%s=%016x%s", m.name, fp, eol)
+		binary.LittleEndian.PutUint64(b[:], fp)
+		dataset.Write(b[:])
+	}
+	fmt.Fprintf(bw, "dataset=%016x%s", dataset.Sum64(), eol)
+	return bw.Flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprintIgnoresRowOrder(t *testing.T) {
+	rows := []string{"Hamburg;12.0", "Bulawayo;8.9", "Palembang;38.8", "Hamburg;-3.4", "Bulawayo;-1.0"}
+	permuted := []string{rows[3], rows[1], rows[4], rows[2], rows[0]}
+
+	var fingerprints []string
+	for _, input := range [][]string{rows, permuted} {
+		data := New()
+		process(data, []byte(strings.Join(input, "\n")+"\n"))
+
+		var sb strings.Builder
+		if err := writeFingerprints(&sb, sortedResults(data), "\n"); err != nil {
+			t.Fatal(err)
+		}
+		fingerprints = append(fingerprints, sb.String())
+	}
+
+	if fingerprints[0] != fingerprints[1] {
+		t.Errorf("Wrong fingerprints for permuted rows, expected:\n%s\ngot:\n%s", fingerprints[0], fingerprints[1])
+	}
+	if lines := strings.Split(strings.TrimSuffix(fingerprints[0], "\n"), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[3], "dataset=") {
+		t.Errorf("Expected a fingerprint per station and one for the dataset, got:\n%s", fingerprints[0])
+	}
+
+	data := New()
+	process(data, []byte(strings.Join(rows[1:], "\n")+"\n"))
+	var sb strings.Builder
+	if err := writeFingerprints(&sb, sortedResults(data), "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() == fingerprints[0] {
+		t.Errorf("Expected a different fingerprint without the first row")
+	}
+}
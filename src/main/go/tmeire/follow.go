@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"time"
+)
+
+// followPoll is how long follow waits before reading again after reaching the end of the file.
+const followPoll = 100 * time.Millisecond
+
+// follow aggregates everything read from file, including data appended after reaching its end,
+// and passes a sorted snapshot of the results to snapshot every interval until stop is closed or reading fails.
+func follow(file io.Reader, interval time.Duration, p *parser, stop <-chan struct{}, snapshot func([]*measurement)) error {
+	chunks := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		for {
+			b := make([]byte, 64*1024)
+			n, err := file.Read(b)
+			if n > 0 {
+				select {
+				case chunks <- b[:n]:
+				case <-stop:
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					errs <- err
+					return
+				}
+				select {
+				case <-time.After(min(followPoll, interval)):
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	data := New()
+	var pending []byte
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				select {
+				case err := <-errs:
+					return err
+				default:
+					return nil
+				}
+			}
+			pending = append(pending, chunk...)
+
+			// Only process full lines, the remainder is kept in a new buffer as the stored names point into this one
+			if idx := p.recordEnd(pending); idx >= 0 {
+				p.process(data, pending[:idx+1])
+				pending = slices.Clone(pending[idx+1:])
+			}
+		case <-ticker.C:
+			snapshot(sortedResults(data))
+		case <-stop:
+			return nil
+		}
+	}
+}
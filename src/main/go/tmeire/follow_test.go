@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	snapshots := make(chan map[string]int64)
+	go follow(r, 5*time.Millisecond, &parser{}, stop, func(results []*measurement) {
+		counts := make(map[string]int64)
+		for _, m := range results {
+			counts[string(m.name)] = m.count
+		}
+		select {
+		case snapshots <- counts:
+		case <-stop:
+		}
+	})
+
+	waitFor := func(station string, count int64) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case counts := <-snapshots:
+				if counts[station] == count {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("No snapshot with %d readings for %s", count, station)
+			}
+		}
+	}
+
+	io.WriteString(w, "Hamburg;12.0\nBulawayo;8.9\nHam")
+	waitFor("Hamburg", 1)
+
+	io.WriteString(w, "burg;-3.4\nBulawayo;1.2\n")
+	waitFor("Hamburg", 2)
+	waitFor("Bulawayo", 2)
+}
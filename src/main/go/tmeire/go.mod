@@ -1,3 +1,11 @@
 module github.com/blackskad/1brc
 
 go 1.22.1
+
+require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/text v0.22.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
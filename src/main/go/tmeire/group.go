@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// compileGroupRegex compiles a regular expression for groupBy, which needs a capture group.
+func compileGroupRegex(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("regular expression %q has no capture group", expr)
+	}
+	return re, nil
+}
+
+// groupBy merges the stations of data by the first capture group of re in their names. Names that don't
+// match keep their full name, or are dropped if skip is set. The measurements of data are reused.
+func groupBy(data measurements, re *regexp.Regexp, skip bool) measurements {
+	grouped := New()
+	for _, m := range data.Flatten() {
+		match := re.FindSubmatchIndex(m.name)
+		switch {
+		case match != nil && match[2] >= 0:
+			m.name = m.name[match[2]:match[3]]
+		case skip:
+			continue
+		}
+		grouped.AddMeasurement(m)
+	}
+	return grouped
+}
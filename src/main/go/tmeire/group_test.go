@@ -0,0 +1,39 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+	re, err := compileGroupRegex(`^(\w+?)_\d+$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []byte("Tokyo_1;12.0\nTokyo_2;-3.4\nTokyo_2;20.0\nOsaka_12;8.9\nHamburg;5.0\n")
+
+	for _, tc := range []struct {
+		skip     bool
+		expected map[string]Stats
+	}{
+		{skip: false, expected: map[string]Stats{
+			"Tokyo":   {Min: -34, Max: 200, Sum: 286, Count: 3},
+			"Osaka":   {Min: 89, Max: 89, Sum: 89, Count: 1},
+			"Hamburg": {Min: 50, Max: 50, Sum: 50, Count: 1},
+		}},
+		{skip: true, expected: map[string]Stats{
+			"Tokyo": {Min: -34, Max: 200, Sum: 286, Count: 3},
+			"Osaka": {Min: 89, Max: 89, Sum: 89, Count: 1},
+		}},
+	} {
+		data := New()
+		process(data, input)
+		if got := groupBy(data, re, tc.skip).Stats(); !maps.Equal(got, tc.expected) {
+			t.Errorf("Wrong grouping with skip %v, expected: %v, got: %v", tc.skip, tc.expected, got)
+		}
+	}
+
+	if _, err := compileGroupRegex(`\w+_\d+`); err == nil {
+		t.Errorf("Expected an error for a regular expression without a capture group")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// gzipChunkSize is the compressed size from which consecutive gzip members are decompressed as one chunk.
+var gzipChunkSize int64 = 16 * 1024 * 1024
+
+// gzipIndexHeader starts a gzip index, followed by the size of the indexed file and one member offset per line.
+const gzipIndexHeader = "gzip-index v1"
+
+// aggregateIndexedGzip aggregates a gzip file of several members, like the output of pigz --independent or bgzip,
+// decompressing chunks of members in parallel. The member offsets are loaded from indexPath, or found with a serial
+// pass over the file and stored there if it doesn't have an index of the file yet. A file with a single member
+// is still aggregated correctly, but isn't decompressed any faster.
+func aggregateIndexedGzip(file *os.File, indexPath string, p *parser) (measurements, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := loadGzipIndex(indexPath, info.Size())
+	if err != nil {
+		if offsets, err = buildGzipIndex(io.NewSectionReader(file, 0, info.Size())); err != nil {
+			return nil, err
+		}
+		if err := writeGzipIndex(indexPath, info.Size(), offsets); err != nil {
+			return nil, err
+		}
+	}
+
+	r := newParallelGzipReader(file, gzipChunks(offsets, info.Size()), defaultWorkers())
+	defer r.Close()
+	// Fill whole blocks, instead of handing every chunk to a worker as a block of its own
+	return aggregate(fullReader{r}, blockSize, p)
+}
+
+// countingReader counts the bytes read from r. As it's an io.ByteReader, flate reads exactly the bytes it needs
+// instead of buffering ahead, so the count is the offset right after the last gzip member read.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// buildGzipIndex returns the offsets of the members of the gzip stream in r, decompressing them one by one.
+func buildGzipIndex(r io.Reader) ([]int64, error) {
+	cr := &countingReader{r: bufio.NewReaderSize(r, 1024*1024)}
+	zr, err := gzip.NewReader(cr)
+	if err != nil {
+		return nil, err
+	}
+	offsets := []int64{0}
+	for {
+		zr.Multistream(false)
+		if _, err := io.Copy(io.Discard, zr); err != nil {
+			return nil, fmt.Errorf("gzip member at offset %d: %w", offsets[len(offsets)-1], err)
+		}
+		offset := cr.n
+		if err := zr.Reset(cr); errors.Is(err, io.EOF) {
+			return offsets, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("gzip member at offset %d: %w", offset, err)
+		}
+		offsets = append(offsets, offset)
+	}
+}
+
+// loadGzipIndex loads the member offsets of a file of size bytes from an index written by writeGzipIndex.
+// It fails for an index of a file of another size, which is most likely another file.
+func loadGzipIndex(path string, size int64) ([]int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) < 3 || lines[0] != gzipIndexHeader || lines[1] != strconv.FormatInt(size, 10) {
+		return nil, fmt.Errorf("%s is not a gzip index of a file of %d bytes", path, size)
+	}
+	offsets := make([]int64, 0, len(lines)-2)
+	for _, line := range lines[2:] {
+		offset, err := strconv.ParseInt(line, 10, 64)
+		if err != nil || offset < 0 || offset >= size || (len(offsets) > 0 && offset <= offsets[len(offsets)-1]) {
+			return nil, fmt.Errorf("%s: invalid member offset %q", path, line)
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+func writeGzipIndex(path string, size int64, offsets []int64) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n%d\n", gzipIndexHeader, size)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%d\n", offset)
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+// gzipChunk is a range of whole gzip members.
+type gzipChunk struct {
+	offset, length int64
+}
+
+// gzipChunks groups the members starting at offsets in a file of size bytes into chunks of at least gzipChunkSize.
+func gzipChunks(offsets []int64, size int64) []gzipChunk {
+	var chunks []gzipChunk
+	start := int64(0)
+	for _, offset := range offsets[1:] {
+		if offset-start >= gzipChunkSize {
+			chunks = append(chunks, gzipChunk{start, offset - start})
+			start = offset
+		}
+	}
+	return append(chunks, gzipChunk{start, size - start})
+}
+
+// parallelGzipReader reads the decompressed chunks of a gzip file in order, while up to workers chunks
+// ahead of it are decompressed concurrently.
+type parallelGzipReader struct {
+	pending chan chan gzipResult
+	done    chan struct{}
+	cur     []byte
+	err     error
+}
+
+type gzipResult struct {
+	data []byte
+	err  error
+}
+
+func newParallelGzipReader(r io.ReaderAt, chunks []gzipChunk, workers int) *parallelGzipReader {
+	pr := &parallelGzipReader{pending: make(chan chan gzipResult, workers-1), done: make(chan struct{})}
+	go func() {
+		defer close(pr.pending)
+		for _, c := range chunks {
+			result := make(chan gzipResult, 1)
+			select {
+			case pr.pending <- result:
+			case <-pr.done:
+				return
+			}
+			go func(c gzipChunk) {
+				data, err := decompressChunk(io.NewSectionReader(r, c.offset, c.length))
+				result <- gzipResult{data, err}
+			}(c)
+		}
+	}()
+	return pr
+}
+
+// decompressChunk decompresses all the gzip members in r.
+func decompressChunk(r io.Reader) ([]byte, error) {
+	zr, err := gzip.NewReader(bufio.NewReaderSize(r, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(zr); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (pr *parallelGzipReader) Read(b []byte) (int, error) {
+	for len(pr.cur) == 0 {
+		if pr.err != nil {
+			return 0, pr.err
+		}
+		result, ok := <-pr.pending
+		if !ok {
+			pr.err = io.EOF
+			continue
+		}
+		res := <-result
+		pr.cur, pr.err = res.data, res.err
+	}
+	n := copy(b, pr.cur)
+	pr.cur = pr.cur[n:]
+	return n, nil
+}
+
+// Close stops decompressing chunks ahead of the reader.
+func (pr *parallelGzipReader) Close() error {
+	close(pr.done)
+	return nil
+}
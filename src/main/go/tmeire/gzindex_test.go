@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIndexedGzip(t *testing.T) {
+	defer func(size int64) { gzipChunkSize = size }(gzipChunkSize)
+	gzipChunkSize = 2000
+
+	// Members of 500 rows each, like bgzip or pigz --independent write them
+	var plain, compressed bytes.Buffer
+	var offsets []int64
+	for member := 0; member < 10; member++ {
+		offsets = append(offsets, int64(compressed.Len()))
+		zw := gzip.NewWriter(&compressed)
+		for i := 0; i < 500; i++ {
+			line := fmt.Sprintf("Station%d;%d.%d\n", (i*7+member)%31, (i*member)%100-50, i%10)
+			plain.WriteString(line)
+			zw.Write([]byte(line))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := buildGzipIndex(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, offsets) {
+		t.Fatalf("Wrong member offsets, expected: %v, got: %v", offsets, got)
+	}
+	if chunks := gzipChunks(offsets, int64(compressed.Len())); len(chunks) < 2 || len(chunks) >= len(offsets) {
+		t.Errorf("Expected the members to be grouped in several chunks, got: %v", chunks)
+	}
+
+	dir := t.TempDir()
+	filename, index := filepath.Join(dir, "measurements.txt.gz"), filepath.Join(dir, "measurements.txt.gz.idx")
+	if err := os.WriteFile(filename, compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expected := New()
+	process(expected, plain.Bytes())
+
+	// The first run builds the index, the second one loads it
+	for run := 0; run < 2; run++ {
+		data, err := aggregateFile(filename, &parser{gzipIndex: index})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(data.Stats(), expected.Stats()) {
+			t.Errorf("Wrong aggregation of the indexed gzip in run %d", run)
+		}
+		if loaded, err := loadGzipIndex(index, int64(compressed.Len())); err != nil || !slices.Equal(loaded, offsets) {
+			t.Errorf("Wrong stored index after run %d, expected: %v, got: %v (%v)", run, offsets, loaded, err)
+		}
+	}
+
+	// An index of another file is rebuilt
+	if _, err := loadGzipIndex(index, int64(compressed.Len()+1)); err == nil {
+		t.Error("Expected an error loading the index of a file of another size")
+	}
+}
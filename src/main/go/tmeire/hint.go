@@ -0,0 +1,14 @@
+package main
+
+import "os"
+
+// openSequential opens the file and hints the OS that it will be read sequentially, where supported.
+func openSequential(filename string) (*os.File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	// The hint is only an optimisation, reading works the same without it
+	_ = hintSequential(file)
+	return file, nil
+}
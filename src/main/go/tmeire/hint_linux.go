@@ -0,0 +1,24 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const fadvSequential = 2 // POSIX_FADV_SEQUENTIAL
+
+// fadvise is a variable so tests can check the hint is given.
+var fadvise = func(fd uintptr, offset, length int64, advice int) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, fd, uintptr(offset), uintptr(length), uintptr(advice), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// hintSequential advises the kernel to read ahead aggressively for the whole file.
+func hintSequential(file *os.File) error {
+	return fadvise(file.Fd(), 0, 0, fadvSequential)
+}
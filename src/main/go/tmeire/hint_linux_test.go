@@ -0,0 +1,46 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSequentialHints(t *testing.T) {
+	input := []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, input, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var advices []int
+	orig := fadvise
+	fadvise = func(fd uintptr, offset, length int64, advice int) error {
+		advices = append(advices, advice)
+		return orig(fd, offset, length, advice)
+	}
+	defer func() { fadvise = orig }()
+
+	file, err := openSequential(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if len(advices) != 1 || advices[0] != fadvSequential {
+		t.Errorf("Expected a single sequential hint, got: %v", advices)
+	}
+
+	expected := New()
+	process(expected, input)
+	got, err := collectData(file, 16, 2, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), got.Stats())
+	}
+}
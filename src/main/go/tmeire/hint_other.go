@@ -0,0 +1,9 @@
+//go:build !(linux && (amd64 || arm64))
+
+package main
+
+import "os"
+
+func hintSequential(file *os.File) error {
+	return nil
+}
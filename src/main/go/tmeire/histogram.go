@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A histogram counts the readings per tenth of a degree between histogramMin and histogramMax.
+// Readings outside of that range are counted in the outermost bins.
+type histogram []uint32
+
+const (
+	histogramMin = -999
+	histogramMax = 999
+)
+
+func newHistogram() histogram {
+	return make(histogram, histogramMax-histogramMin+1)
+}
+
+func (h histogram) Add(temperature int64) {
+	h[min(max(temperature, histogramMin), histogramMax)-histogramMin]++
+}
+
+func (h histogram) Merge(h1 histogram) {
+	for i, n := range h1 {
+		h[i] += n
+	}
+}
+
+// Each calls fn with the temperature and count of every non-empty bin, in increasing temperature order.
+func (h histogram) Each(fn func(temperature int64, count uint32)) {
+	for i, n := range h {
+		if n > 0 {
+			fn(int64(i)+histogramMin, n)
+		}
+	}
+}
+
+// Distinct returns the number of distinct readings, the non-empty bins.
+func (h histogram) Distinct() int {
+	n := 0
+	for _, c := range h {
+		if c > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// TrimmedMean returns the mean in tenths of a degree of the readings left after discarding the lowest
+// and highest pct percent of them, pct below 50.
+func (h histogram) TrimmedMean(pct float64) float64 {
+	var total int64
+	for _, n := range h {
+		total += int64(n)
+	}
+	trim := int64(float64(total) * pct / 100)
+	lo, hi := trim, total-trim
+
+	// Only count the ranks of every bin between lo and hi
+	var sum, rank int64
+	h.Each(func(temperature int64, count uint32) {
+		if kept := min(rank+int64(count), hi) - max(rank, lo); kept > 0 {
+			sum += kept * temperature
+		}
+		rank += int64(count)
+	})
+	return float64(sum) / float64(hi-lo)
+}
+
+// Median returns the median in tenths of a degree, interpolated within the bin of the middle rank
+// as if the readings of every bin were spread evenly over its tenth of a degree.
+func (h histogram) Median() float64 {
+	var total int64
+	for _, n := range h {
+		total += int64(n)
+	}
+	half := float64(total) / 2
+
+	var rank int64
+	for i, n := range h {
+		if n > 0 && float64(rank+int64(n)) >= half {
+			return float64(int64(i)+histogramMin) - 0.5 + (half-float64(rank))/float64(n)
+		}
+		rank += int64(n)
+	}
+	return 0
+}
+
+// degreeHistogram sums the histograms of results into a count of readings per whole degree, rounded down,
+// indexed from degreeHistogramMin.
+func degreeHistogram(results []*measurement) []uint64 {
+	counts := make([]uint64, floorDegree(histogramMax)-degreeHistogramMin+1)
+	for _, m := range results {
+		m.hist.Each(func(temperature int64, count uint32) {
+			counts[floorDegree(temperature)-degreeHistogramMin] += uint64(count)
+		})
+	}
+	return counts
+}
+
+var degreeHistogramMin = floorDegree(histogramMin)
+
+// floorDegree returns the whole degree below a temperature in tenths.
+func floorDegree(temperature int64) int64 {
+	if temperature < 0 {
+		return (temperature - 9) / 10
+	}
+	return temperature / 10
+}
+
+// writeDegreeHistogram writes a degree=count line for every degree with readings.
+func writeDegreeHistogram(w io.Writer, counts []uint64, eol string) error {
+	bw := bufio.NewWriter(w)
+	for i, n := range counts {
+		if n > 0 {
+			fmt.Fprintf(bw, "%d=%d%s", int64(i)+degreeHistogramMin, n, eol)
+		}
+	}
+	return bw.Flush()
+}
+
+// checkHistogramInput returns an error if p tracks histograms of input that has no single readings,
+// the pre-aggregated rows or the dumps and csv shards of the merge, reduce and kmerge commands.
+func checkHistogramInput(p *parser, command string) error {
+	if !p.histogram {
+		return nil
+	}
+	if p.preaggregated {
+		return errors.New("can't track histograms of pre-aggregated rows, they have no single readings")
+	}
+	switch command {
+	case "merge", "reduce", "kmerge":
+		return fmt.Errorf("can't track histograms with %s, its input has no single readings", command)
+	}
+	return nil
+}
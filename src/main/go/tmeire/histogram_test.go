@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDegreeHistogram(t *testing.T) {
+	data := New()
+	p := &parser{histogram: true}
+	p.process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHamburg;12.9\nBulawayo;-0.1\nHamburg;-3.0\nBulawayo;0.0\n"))
+	results := sortedResults(data)
+
+	counts := degreeHistogram(results)
+	var total, rows uint64
+	for _, n := range counts {
+		total += n
+	}
+	for _, m := range results {
+		rows += uint64(m.count)
+	}
+	if total != rows {
+		t.Errorf("Degree histogram sums to %d, expected: %d", total, rows)
+	}
+
+	var sb strings.Builder
+	if err := writeDegreeHistogram(&sb, counts, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "-4=1\n-3=1\n-1=1\n0=1\n8=1\n12=2\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong degree histogram, expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestTrimmedMean(t *testing.T) {
+	// A skewed distribution with a few extreme outliers on both ends
+	var readings []int64
+	for i := int64(0); i < 200; i++ {
+		readings = append(readings, (i*i)%317-100)
+	}
+	readings = append(readings, 999, 999, 998, -999, 985)
+
+	h := newHistogram()
+	for _, r := range readings {
+		h.Add(r)
+	}
+	sorted := slices.Clone(readings)
+	slices.Sort(sorted)
+
+	for _, pct := range []float64{0, 1, 5, 10, 25, 49} {
+		trim := int(float64(len(sorted)) * pct / 100)
+		kept := sorted[trim : len(sorted)-trim]
+		var sum int64
+		for _, r := range kept {
+			sum += r
+		}
+		expected := float64(sum) / float64(len(kept))
+		if got := h.TrimmedMean(pct); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("Wrong %v%% trimmed mean, expected: %v, got: %v", pct, expected, got)
+		}
+	}
+}
+
+func TestDistinctValues(t *testing.T) {
+	data := New()
+	p := &parser{histogram: true}
+	// Hamburg reports whole degrees, Bulawayo half degrees and Abha a single value
+	p.process(data, []byte("Hamburg;12.0\nHamburg;13.0\nHamburg;12.0\nHamburg;-3.0\nBulawayo;8.5\nBulawayo;9.0\n"+
+		"Bulawayo;9.5\nBulawayo;9.0\nBulawayo;8.5\nAbha;18.3\nAbha;18.3\nAbha;18.3\n"))
+
+	cols, err := parseColumns("station,count,distinct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := writeCSV(&sb, sortedResults(data), cols, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "station,count,distinct\nAbha,3,1\nBulawayo,5,3\nHamburg,4,3\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong distinct values, expected: %q, got: %q", expected, sb.String())
+	}
+}
+
+func TestMedian(t *testing.T) {
+	for _, readings := range [][]int64{
+		{123},
+		{-34, 120, 89},
+		{10, 20, 30, 40},
+		{5, 5, 5, 6, 7, 7, 100, -50, 5, 6},
+		{-999, -999, 999, 999, 999, 0},
+	} {
+		h := newHistogram()
+		for _, r := range readings {
+			h.Add(r)
+		}
+		sorted := slices.Clone(readings)
+		slices.Sort(sorted)
+		n := len(sorted)
+		expected := float64(sorted[n/2])
+		if n%2 == 0 {
+			expected = float64(sorted[n/2-1]+sorted[n/2]) / 2
+		}
+
+		// The interpolation stays within half a tenth of the exact median, or between the two middle readings
+		lo, hi := float64(sorted[(n-1)/2])-0.5, float64(sorted[n/2])+0.5
+		if got := h.Median(); got < lo || got > hi {
+			t.Errorf("Wrong median of %v, expected about: %v, got: %v", readings, expected, got)
+		}
+	}
+}
+
+func TestHistogramsNeedReadings(t *testing.T) {
+	dir := t.TempDir()
+	preaggregated := filepath.Join(dir, "preaggregated.txt")
+	if err := os.WriteFile(preaggregated, []byte("Hamburg;24.6;2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dump := filepath.Join(dir, "dump.bin")
+	if err := writeBufferedDumpFile(dump, []*measurement{{name: []byte("Hamburg"), min: 120, max: 126, sum: 246, count: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"-preaggregated", "-median", preaggregated}, err: "pre-aggregated rows"},
+		{args: []string{"-median", "merge", dump}, err: "with merge"},
+		{args: []string{"-median", "reduce", dump}, err: "with reduce"},
+		{args: []string{"-csv-columns", "station,median", "kmerge", preaggregated}, err: "with kmerge"},
+		{args: []string{"-preaggregated", "-trimmed-mean", "10", preaggregated}, err: "pre-aggregated rows"},
+		{args: []string{"-trimmed-mean", "10", "merge", dump}, err: "with merge"},
+		{args: []string{"-preaggregated", "-distinct-values", preaggregated}, err: "pre-aggregated rows"},
+		{args: []string{"-distinct-values", "reduce", dump}, err: "with reduce"},
+	} {
+		if stderr := runFailingCalc(t, nil, tc.args...); !strings.Contains(stderr, tc.err) {
+			t.Errorf("Wrong error for %v, expected: %s, got: %s", tc.args, tc.err, stderr)
+		}
+	}
+}
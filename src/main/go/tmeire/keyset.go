@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+// keysetBuckets sizes the bucket table for inputs with a known set of station names.
+// Any other input is still aggregated correctly, the size only affects the speed.
+var keysetBuckets = map[string]int{
+	// The 10,000 stations of the 1BRC 10k variant spread over a quarter of the default table with at most
+	// a few per bucket, which keeps more of the table in the cache.
+	"10k": 1 << 14,
+}
+
+func useKeyset(name string) error {
+	n, ok := keysetBuckets[name]
+	if !ok {
+		return fmt.Errorf("unknown keyset %q", name)
+	}
+	buckets = n
+	return nil
+}
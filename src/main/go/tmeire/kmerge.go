@@ -0,0 +1,128 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// shard reads the rows of a csv output sorted by station, as written with at least the station,
+// min, max, sum and count columns.
+type shard struct {
+	r                             *csv.Reader
+	station, min, max, sum, count int
+	cur                           *measurement
+}
+
+func newShard(r io.Reader) (*shard, error) {
+	s := &shard{r: csv.NewReader(r)}
+	header, err := s.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, idx := range map[string]*int{"station": &s.station, "min": &s.min, "max": &s.max, "sum": &s.sum, "count": &s.count} {
+		*idx = -1
+		for i, col := range header {
+			if col == name {
+				*idx = i
+			}
+		}
+		if *idx < 0 {
+			return nil, fmt.Errorf("shard is missing the %s column", name)
+		}
+	}
+	return s, nil
+}
+
+// next reads the next row into cur, which is nil once the shard is exhausted.
+func (s *shard) next() error {
+	row, err := s.r.Read()
+	if errors.Is(err, io.EOF) {
+		s.cur = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	m := &measurement{name: []byte(row[s.station])}
+	for idx, v := range map[int]*int64{s.min: &m.min, s.max: &m.max, s.sum: &m.sum} {
+		var ok bool
+		if *v, ok = parseFixed([]byte(row[idx])); !ok {
+			return fmt.Errorf("station %s: invalid value %q", row[s.station], row[idx])
+		}
+	}
+	if m.count, err = strconv.ParseInt(row[s.count], 10, 64); err != nil {
+		return fmt.Errorf("station %s: %w", row[s.station], err)
+	}
+
+	if s.cur != nil && string(m.name) < string(s.cur.name) {
+		return fmt.Errorf("shard is not sorted: %s follows %s", m.name, s.cur.name)
+	}
+	s.cur = m
+	return nil
+}
+
+type shardHeap []*shard
+
+func (h shardHeap) Len() int           { return len(h) }
+func (h shardHeap) Less(i, j int) bool { return string(h[i].cur.name) < string(h[j].cur.name) }
+func (h shardHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *shardHeap) Push(x any)        { *h = append(*h, x.(*shard)) }
+func (h *shardHeap) Pop() any {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+// kmerge merges sorted csv shards, calling emit once per station in sorted order with the station's
+// readings across all shards combined. Only the current row of every shard is held in memory.
+func kmerge(readers []io.Reader, emit func(*measurement) error) error {
+	h := make(shardHeap, 0, len(readers))
+	for _, r := range readers {
+		s, err := newShard(r)
+		if err != nil {
+			return err
+		}
+		if err := s.next(); err != nil {
+			return err
+		}
+		if s.cur != nil {
+			h = append(h, s)
+		}
+	}
+	heap.Init(&h)
+
+	var merged *measurement
+	for h.Len() > 0 {
+		s := h[0]
+		if merged != nil && string(merged.name) == string(s.cur.name) {
+			merged.Merge(s.cur)
+		} else {
+			if merged != nil {
+				if err := emit(merged); err != nil {
+					return err
+				}
+			}
+			merged = s.cur
+		}
+
+		if err := s.next(); err != nil {
+			return err
+		}
+		if s.cur == nil {
+			heap.Pop(&h)
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+	if merged != nil {
+		return emit(merged)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKmerge(t *testing.T) {
+	shards := []string{
+		"Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n",
+		"Palembang;38.8\nBulawayo;-10.1\n",
+		"Abha;5.0\nHamburg;34.2\nPalembang;21.0\nZagreb;1.1\n",
+	}
+
+	cols, err := parseColumns("min,count,station,sum,max")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := New()
+	var readers []io.Reader
+	for _, input := range shards {
+		process(expected, []byte(input))
+
+		data := New()
+		process(data, []byte(input))
+		var sb strings.Builder
+		if err := writeCSV(&sb, sortedResults(data), cols, "\n"); err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, strings.NewReader(sb.String()))
+	}
+
+	merged := New()
+	var order []string
+	err = kmerge(readers, func(m *measurement) error {
+		order = append(order, string(m.name))
+		merged.AddMeasurement(m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"Abha", "Bulawayo", "Hamburg", "Palembang", "Zagreb"}; !slices.Equal(order, expected) {
+		t.Errorf("Wrong station order, expected: %v, got: %v", expected, order)
+	}
+	if !maps.Equal(merged.Stats(), expected.Stats()) {
+		t.Errorf("Wrong merge, expected: %v, got: %v", expected.Stats(), merged.Stats())
+	}
+}
+
+func TestKmergeUnsortedShard(t *testing.T) {
+	shard := "station,min,max,sum,count\nHamburg,1.0,1.0,1.0,1\nBulawayo,1.0,1.0,1.0,1\n"
+	err := kmerge([]io.Reader{strings.NewReader(shard)}, func(*measurement) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "not sorted") {
+		t.Errorf("Expected an unsorted shard error, got: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// A manifest records how a result was produced.
+type manifest struct {
+	Inputs  []manifestInput   `json:"inputs"`
+	Flags   map[string]string `json:"flags"`
+	Version string            `json:"version"`
+	// OutputSHA256 is the hex encoded SHA-256 hash of the output.
+	OutputSHA256 string `json:"output_sha256"`
+}
+
+type manifestInput struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// newManifest describes a run over the input files with the values of all flags of fset,
+// producing output with the given hash. Stdin, "-", is recorded without a size or modification time.
+func newManifest(inputs []string, fset *flag.FlagSet, outputHash []byte) (*manifest, error) {
+	m := &manifest{Flags: map[string]string{}, Version: version(), OutputSHA256: hex.EncodeToString(outputHash)}
+	for _, path := range inputs {
+		in := manifestInput{Path: path}
+		if path != "-" {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			in.Size, in.ModTime = info.Size(), info.ModTime()
+		}
+		m.Inputs = append(m.Inputs, in)
+	}
+	fset.VisitAll(func(f *flag.Flag) { m.Flags[f.Name] = f.Value.String() })
+	return m, nil
+}
+
+// version returns the module version of the binary, or (devel) if it isn't built from a tagged module.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func (m *manifest) write(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "measurements.txt")
+	if err := os.WriteFile(input, []byte("Hamburg;12.0\nBulawayo;8.9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := flag.NewFlagSet("calc", flag.ContinueOnError)
+	fset.String("format", "text", "")
+	fset.Int("gomaxprocs", 0, "")
+	if err := fset.Parse([]string{"-format", "csv", input}); err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("output"))
+	m, err := newManifest(fset.Args(), fset, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "manifest.json")
+	if err := m.write(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got manifest
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Inputs) != 1 || got.Inputs[0].Path != input || got.Inputs[0].Size != 26 || !got.Inputs[0].ModTime.Equal(info.ModTime()) {
+		t.Errorf("Wrong inputs: %+v", got.Inputs)
+	}
+	if got.Flags["format"] != "csv" || got.Flags["gomaxprocs"] != "0" {
+		t.Errorf("Wrong flags: %v", got.Flags)
+	}
+	if got.Version == "" {
+		t.Errorf("Expected a version")
+	}
+	if got.OutputSHA256 != hex.EncodeToString(hash[:]) {
+		t.Errorf("Wrong output hash, expected: %x, got: %s", hash, got.OutputSHA256)
+	}
+}
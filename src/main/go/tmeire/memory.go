@@ -0,0 +1,40 @@
+package main
+
+import "unsafe"
+
+const (
+	// maxStations is the number of distinct station names the 1BRC rules allow.
+	maxStations = 10000
+	// maxNameLength is the longest station name in bytes the 1BRC rules allow.
+	maxNameLength = 100
+)
+
+// EstimateMemory returns an upper bound of the memory in bytes used to aggregate a file of fileSize bytes
+// in blocks of blockSize bytes with the given number of workers, without histograms:
+//
+//   - every worker holds a block, while the reader fills the next one and another is waiting to be sent,
+//     but there are never more blocks than the file needs. The station names point into the blocks, so every
+//     name in every table may keep another block alive.
+//   - every worker and the collector have their own bucket table with up to maxStations buckets and measurements.
+//     Crowded buckets also index their stations by a copy of the name.
+func EstimateMemory(fileSize int64, blockSize int, workers int) int64 {
+	workers = max(workers, 1)
+	tables := int64(workers + 1)
+	fileBlocks := (fileSize + int64(blockSize) - 1) / int64(blockSize)
+	blocks := min(int64(workers)+2+tables*maxStations, fileBlocks+1) * int64(blockSize)
+
+	ptrSize := int64(unsafe.Sizeof(&measurement{}))
+	// A map needs at most three times its keys and values, counting the free slots after growing
+	indexEntrySize := 3*(int64(unsafe.Sizeof(""))+ptrSize) + maxNameLength
+	// The data slices of the buckets have room for up to twice their stations
+	stationSize := int64(unsafe.Sizeof(measurement{})) + 2*ptrSize + indexEntrySize
+	table := int64(buckets)*int64(unsafe.Sizeof(&bucket{})) + min(int64(buckets), maxStations)*int64(unsafe.Sizeof(bucket{}))
+	return blocks + tables*(table+maxStations*stationSize)
+}
+
+// EstimateHistogramMemory returns the memory in bytes that tracking histograms adds to EstimateMemory,
+// a histogram for every measurement of the tables of the workers and the collector.
+func EstimateHistogramMemory(workers int) int64 {
+	histogramSize := int64(len(newHistogram())) * int64(unsafe.Sizeof(uint32(0)))
+	return int64(max(workers, 1)+1) * maxStations * histogramSize
+}
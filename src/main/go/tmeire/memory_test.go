@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEstimateMemory(t *testing.T) {
+	const size = 1 << 40
+	base := EstimateMemory(size, 1<<20, 4)
+
+	// Every extra worker holds another block, its own tables and the blocks its names keep alive
+	perWorker := EstimateMemory(size, 1<<20, 5) - base
+	if perWorker <= (maxStations+1)<<20 {
+		t.Errorf("Expected an extra worker to add more than %d blocks, got: %d bytes", maxStations+1, perWorker)
+	}
+	if got := EstimateMemory(size, 1<<20, 8) - base; got != 4*perWorker {
+		t.Errorf("Wrong estimate for 4 extra workers, expected: %d, got: %d", 4*perWorker, got)
+	}
+
+	// Doubling the block size doubles the memory of the 4+2 blocks in flight and the 5 tables worth of blocks kept alive
+	blocks := int64(4+2+5*maxStations) << 20
+	if got := EstimateMemory(size, 2<<20, 4) - base; got != blocks {
+		t.Errorf("Wrong estimate for double the block size, expected: %d, got: %d", blocks, got)
+	}
+
+	// A file of 1.5 blocks never needs more than 3 blocks, whatever the number of workers
+	small := EstimateMemory(3<<19, 1<<20, 8)
+	if got := small - (EstimateMemory(size, 1<<20, 8) - int64(8+2+9*maxStations-3)<<20); got != 0 {
+		t.Errorf("Expected only 3 blocks for a small file, got %d bytes more", got)
+	}
+
+	if got := EstimateHistogramMemory(4) - EstimateHistogramMemory(3); got != maxStations*int64(len(newHistogram()))*4 {
+		t.Errorf("Wrong histogram estimate for an extra worker, got: %d", got)
+	}
+}
+
+func TestEstimateMemoryMeasured(t *testing.T) {
+	// New stations keep showing up, so their names keep every block alive
+	var sb strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i/4, i%100-50, i%10)
+	}
+	input := sb.String()
+
+	const blockSize, workers = 4096, 2
+	for _, histograms := range []bool{false, true} {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		data, err := collectData(strings.NewReader(input), blockSize, workers, &parser{histogram: histograms})
+		if err != nil {
+			t.Fatal(err)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(data)
+
+		used := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		estimate := EstimateMemory(int64(len(input)), blockSize, workers)
+		if histograms {
+			estimate += EstimateHistogramMemory(workers)
+		}
+		if used > estimate {
+			t.Errorf("Expected at most %d bytes with histograms %v, the aggregation holds %d", estimate, histograms, used)
+		}
+	}
+}
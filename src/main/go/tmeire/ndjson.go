@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// processNDJSON parses lines of JSON objects like {"station":"Abha","temp":18.3}. Instead of unmarshalling
+// the objects it only extracts the station and temp fields, skipping lines without them.
+func (p *parser) processNDJSON(data measurements, b []byte) {
+	for len(b) > 0 {
+		var line []byte
+		line, b, _ = bytes.Cut(b, []byte{'\n'})
+
+		name, ok := jsonString(jsonField(line, "station"))
+		if !ok || len(name) == 0 {
+			continue
+		}
+		temperature, ok := jsonTenths(jsonField(line, "temp"))
+		if !ok {
+			continue
+		}
+		p.add(data, name, temperature)
+	}
+}
+
+// jsonField returns the raw value of the key field of the JSON object on line, or nil if it has none.
+// It doesn't validate the object, nor look at nesting, which is fine for flat objects.
+func jsonField(line []byte, key string) []byte {
+	quoted := `"` + key + `"`
+	for {
+		idx := bytes.Index(line, []byte(quoted))
+		if idx < 0 {
+			return nil
+		}
+		line = bytes.TrimLeft(line[idx+len(quoted):], " \t")
+		// The key could also be a value, followed by a comma instead of a colon
+		if len(line) == 0 || line[0] != ':' {
+			continue
+		}
+		line = bytes.TrimLeft(line[1:], " \t")
+		return line[:jsonValueEnd(line)]
+	}
+}
+
+// jsonValueEnd returns the length of the string or number value at the start of b.
+func jsonValueEnd(b []byte) int {
+	if len(b) > 0 && b[0] == '"' {
+		for i := 1; i < len(b); i++ {
+			switch b[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return len(b)
+	}
+	end := bytes.IndexAny(b, ",} \t\r")
+	if end < 0 {
+		return len(b)
+	}
+	return end
+}
+
+// jsonString returns the contents of the JSON string v, only unquoting it the slow way if it has escapes.
+func jsonString(v []byte) ([]byte, bool) {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return nil, false
+	}
+	if bytes.IndexByte(v, '\\') < 0 {
+		return v[1 : len(v)-1], true
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return nil, false
+	}
+	return []byte(s), true
+}
+
+// jsonTenths parses the JSON number v into tenths of a degree, rounding numbers with more than one decimal
+// with the rounding mode.
+func jsonTenths(v []byte) (int64, bool) {
+	if t, ok := parseDecimal(v); ok {
+		return t, true
+	}
+	f, err := strconv.ParseFloat(string(v), 64)
+	if err != nil || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return int64(rounding.round(f * 10)), true
+}
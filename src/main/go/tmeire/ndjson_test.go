@@ -0,0 +1,52 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestProcessNDJSON(t *testing.T) {
+	semicolon := "Abha;18.3\nHamburg;12.0\nBulawayo;8.9\nAbha;-5.0\nSão Paulo;21.4\nHamburg;-3.4\n"
+	ndjson := `{"station":"Abha","temp":18.3}
+{"temp": 12, "station": "Hamburg"}
+{"station":"Bulawayo","temp":8.9,"humidity":40}
+
+{"station":"Abha","temp":-5.0}
+not json
+{"id":"station","station":"São Paulo","temp":21.4}
+{"station":"Hamburg"}
+{"station":"Hamburg","temp":-3.4}
+`
+	expected, err := aggregate(strings.NewReader(semicolon), 16, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := aggregate(strings.NewReader(ndjson), 128, &parser{ndjson: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong NDJSON aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+}
+
+func TestJSONTenths(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected int64
+		ok       bool
+	}{
+		{input: "18.3", expected: 183, ok: true},
+		{input: "-5", expected: -50, ok: true},
+		{input: "12.34", expected: 123, ok: true},
+		{input: "1e1", expected: 100, ok: true},
+		{input: `"12.0"`},
+		{input: ""},
+	} {
+		got, ok := jsonTenths([]byte(tc.input))
+		if ok != tc.ok || got != tc.expected {
+			t.Errorf("Wrong temperature for %q, expected: %d %v, got: %d %v", tc.input, tc.expected, tc.ok, got, ok)
+		}
+	}
+}
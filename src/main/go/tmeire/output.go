@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+type output struct {
+	format  string
+	columns []column
+	eol     string
+
+	// precision is the number of decimals per field of the text format.
+	precision precision
+
+	// compatJava prints the text format exactly like the reference Java implementation.
+	compatJava bool
+
+	// tenthsInt prints the text format as integer tenths of a degree.
+	tenthsInt bool
+
+	// header starts the csv and tsv formats with the unitsHeader comment line.
+	header bool
+
+	// meanAbove and meanBelow only keep the stations with a mean above or below them, if set.
+	meanAbove, meanBelow *int64
+
+	// collator orders the station names for a locale instead of by bytes, if set.
+	collator *collate.Collator
+
+	// spread adds the range between the min and max of every station, in every format but compatJava.
+	// The csv and tsv formats print it as the spread column.
+	spread bool
+}
+
+// write writes the sorted results to w in the configured format.
+func (o *output) write(w io.Writer, results []*measurement) error {
+	if o.meanAbove != nil || o.meanBelow != nil {
+		results = filterByMean(results, o.meanAbove, o.meanBelow)
+	}
+	if o.collator != nil {
+		slices.SortStableFunc(results, func(m1, m2 *measurement) int {
+			return o.collator.Compare(m1.name, m2.name)
+		})
+	}
+
+	switch o.format {
+	case "text":
+		bw := bufio.NewWriter(w)
+		if o.compatJava {
+			printJava(bw, results, o.eol)
+		} else if o.tenthsInt {
+			printTenths(bw, results, o.spread, o.eol)
+		} else {
+			printMeasurements(bw, results, o.precision, o.spread, o.eol)
+		}
+		return bw.Flush()
+	case "csv", "tsv":
+		if o.header {
+			if _, err := fmt.Fprint(w, unitsHeader+o.eol); err != nil {
+				return err
+			}
+		}
+		if o.format == "tsv" {
+			return writeDelimited(w, results, o.columns, o.eol, '\t')
+		}
+		return writeCSV(w, results, o.columns, o.eol)
+	case "ndjson":
+		return writeNDJSON(w, results, o.spread, o.eol)
+	case "table":
+		return writeTable(w, results, o.spread, o.eol)
+	case "compact":
+		return writeCompact(w, results, o.spread, o.eol)
+	case "dump":
+		return writeDump(w, results)
+	case "protobuf":
+		return writeProtobuf(w, results, o.spread)
+	default:
+		return fmt.Errorf("unknown output format %q", o.format)
+	}
+}
+
+// unitsHeader describes the values of the csv and tsv formats. Readers can skip it as a comment,
+// like a csv.Reader with Comment set to '#'.
+const unitsHeader = "# temperatures in Celsius, mean rounded to 1 decimal"
+
+// precision is the number of decimals of the min, mean and max. It implements flag.Value.
+type precision struct {
+	min, mean, max int
+}
+
+func (p *precision) String() string {
+	return fmt.Sprintf("min=%d,mean=%d,max=%d", p.min, p.mean, p.max)
+}
+
+func (p *precision) Set(s string) error {
+	for _, field := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(field, "=")
+		decimals, err := strconv.Atoi(value)
+		if err != nil || decimals < 1 {
+			return fmt.Errorf("invalid number of decimals %q for %s", value, name)
+		}
+		switch name {
+		case "min":
+			p.min = decimals
+		case "mean":
+			p.mean = decimals
+		case "max":
+			p.max = decimals
+		default:
+			return fmt.Errorf("unknown field %q, expected min, mean or max", name)
+		}
+	}
+	return nil
+}
+
+type column struct {
+	name  string
+	value func(m *measurement) string
+}
+
+// histogramColumns are the columns computed from the histogram of every station.
+var histogramColumns = map[string]bool{"distinct": true, "median": true, "trimmed_mean": true}
+
+// needsHistogram reports whether any of the columns is computed from the histograms.
+func needsHistogram(cols []column) bool {
+	return slices.ContainsFunc(cols, func(c column) bool { return histogramColumns[c.name] })
+}
+
+func formatDegrees(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+var columns = []column{
+	{"station", func(m *measurement) string { return string(m.name) }},
+	{"min", func(m *measurement) string { return formatDegrees(float64(m.min) / 10.) }},
+	{"mean", func(m *measurement) string { return formatDegrees(m.mean()) }},
+	{"max", func(m *measurement) string { return formatDegrees(float64(m.max) / 10.) }},
+	{"count", func(m *measurement) string { return strconv.FormatInt(m.count, 10) }},
+	{"sum", func(m *measurement) string { return formatDegrees(float64(m.sum) / 10.) }},
+	{"stddev", func(m *measurement) string { return formatDegrees(m.stddev()) }},
+	{"spread", func(m *measurement) string { return formatDegrees(float64(m.spread()) / 10.) }},
+	// Computed from the histograms, see histogramColumns
+	{"distinct", func(m *measurement) string { return strconv.Itoa(m.hist.Distinct()) }},
+	{"median", func(m *measurement) string { return formatDegrees(m.hist.Median() / 10.) }},
+	// The standard error shrinks with the count, a single decimal would round most stations to 0
+	{"sem", func(m *measurement) string { return strconv.FormatFloat(m.sem(), 'f', 3, 64) }},
+}
+
+// trimmedMeanColumn is the trimmed_mean column of the mean without the lowest and highest pct percent readings,
+// which needs the histogram of every station.
+func trimmedMeanColumn(pct float64) column {
+	return column{"trimmed_mean", func(m *measurement) string {
+		return formatDegrees(math.Round(m.hist.TrimmedMean(pct)) / 10.)
+	}}
+}
+
+func columnNames() string {
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, ",")
+}
+
+// addColumn returns the comma separated column names s with name appended, unless s already lists it.
+func addColumn(s, name string) string {
+	if slices.Contains(strings.Split(s, ","), name) {
+		return s
+	}
+	return s + "," + name
+}
+
+func parseColumns(s string) ([]column, error) {
+	var res []column
+	for _, name := range strings.Split(s, ",") {
+		idx := slices.IndexFunc(columns, func(c column) bool { return c.name == name })
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown csv column %q, expected one of %s", name, columnNames())
+		}
+		res = append(res, columns[idx])
+	}
+	return res, nil
+}
+
+// writeCSV writes a header row with the column names, followed by one row per measurement.
+func writeCSV(w io.Writer, results []*measurement, cols []column, eol string) error {
+	return writeDelimited(w, results, cols, eol, ',')
+}
+
+// writeDelimited writes the results like writeCSV, with comma separating the fields.
+func writeDelimited(w io.Writer, results []*measurement, cols []column, eol string, comma rune) error {
+	cw, err := newDelimitedWriter(w, cols, eol, comma)
+	if err != nil {
+		return err
+	}
+	for _, m := range results {
+		if err := cw.Write(m); err != nil {
+			return err
+		}
+	}
+	return cw.Flush()
+}
+
+// csvWriter writes measurements as csv rows one at a time, after a header row written on creation.
+type csvWriter struct {
+	cw   *csv.Writer
+	cols []column
+	row  []string
+}
+
+// newCSVWriter ends the rows with eol, which is either "\n" or "\r\n".
+func newCSVWriter(w io.Writer, cols []column, eol string) (*csvWriter, error) {
+	return newDelimitedWriter(w, cols, eol, ',')
+}
+
+func newDelimitedWriter(w io.Writer, cols []column, eol string, comma rune) (*csvWriter, error) {
+	c := &csvWriter{cw: csv.NewWriter(w), cols: cols, row: make([]string, len(cols))}
+	c.cw.Comma = comma
+	c.cw.UseCRLF = eol == "\r\n"
+	for i, col := range cols {
+		c.row[i] = col.name
+	}
+	return c, c.cw.Write(c.row)
+}
+
+func (c *csvWriter) Write(m *measurement) error {
+	for i, col := range c.cols {
+		c.row[i] = col.value(m)
+	}
+	return c.cw.Write(c.row)
+}
+
+func (c *csvWriter) Flush() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func newCollator(locale string) (*collate.Collator, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, err
+	}
+	return collate.New(tag), nil
+}
+
+type jsonMeasurement struct {
+	Station   string         `json:"station"`
+	Min       json.Number    `json:"min"`
+	Mean      json.Number    `json:"mean"`
+	Max       json.Number    `json:"max"`
+	Count     int64          `json:"count"`
+	Spread    json.Number    `json:"spread,omitempty"`
+	Histogram []histogramBin `json:"histogram,omitempty"`
+}
+
+type histogramBin struct {
+	Value json.Number `json:"value"`
+	Count uint32      `json:"count"`
+}
+
+// writeNDJSON writes one JSON object per line for each measurement, including the spread if set
+// and the non-empty histogram bins if tracked.
+func writeNDJSON(w io.Writer, results []*measurement, spread bool, eol string) error {
+	bw := bufio.NewWriter(w)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	for _, m := range results {
+		jm := jsonMeasurement{
+			Station: string(m.name),
+			Min:     json.Number(formatDegrees(float64(m.min) / 10.)),
+			Mean:    json.Number(formatDegrees(m.mean())),
+			Max:     json.Number(formatDegrees(float64(m.max) / 10.)),
+			Count:   m.count,
+		}
+		if spread {
+			jm.Spread = json.Number(formatDegrees(float64(m.spread()) / 10.))
+		}
+		m.hist.Each(func(t int64, n uint32) {
+			jm.Histogram = append(jm.Histogram, histogramBin{json.Number(formatDegrees(float64(t) / 10.)), n})
+		})
+		buf.Reset()
+		if err := enc.Encode(jm); err != nil {
+			return err
+		}
+		// Encode always ends the object with a newline
+		bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+		bw.WriteString(eol)
+	}
+	return bw.Flush()
+}
+
+// lineTerminators are the values accepted by --output-eol.
+var lineTerminators = map[string]string{"lf": "\n", "crlf": "\r\n"}
+
+// writeTable writes the results as a table with a header, the names padded to the widest one
+// and the values right aligned.
+func writeTable(w io.Writer, results []*measurement, spread bool, eol string) error {
+	rows := [][]string{{"station", "min", "mean", "max"}}
+	if spread {
+		rows[0] = append(rows[0], "spread")
+	}
+	for _, m := range results {
+		row := []string{string(m.name), formatDegrees(float64(m.min) / 10.), formatDegrees(m.mean()), formatDegrees(float64(m.max) / 10.)}
+		if spread {
+			row = append(row, formatDegrees(float64(m.spread())/10.))
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, v := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(v))
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, row := range rows {
+		// Pad by runes instead of bytes so multi-byte names line up in a terminal
+		bw.WriteString(row[0] + strings.Repeat(" ", widths[0]-utf8.RuneCountInString(row[0])))
+		for i, v := range row[1:] {
+			fmt.Fprintf(bw, "  %*s", widths[i+1], v)
+		}
+		bw.WriteString(eol)
+	}
+	return bw.Flush()
+}
+
+// writeCompact writes the results on a single line of space separated station:min/mean/max fields, to embed in a log line.
+// With spread, the fields end in /spread.
+func writeCompact(w io.Writer, results []*measurement, spread bool, eol string) error {
+	bw := bufio.NewWriter(w)
+	for i, m := range results {
+		if i > 0 {
+			bw.WriteByte(' ')
+		}
+		fmt.Fprintf(bw, "%s:%.1f/%.1f/%.1f", m.name, float64(m.min)/10., m.mean(), float64(m.max)/10.)
+		if spread {
+			fmt.Fprintf(bw, "/%.1f", float64(m.spread())/10.)
+		}
+	}
+	bw.WriteString(eol)
+	return bw.Flush()
+}
+
+// createOutput creates the output file, gzip compressed if compress is set or the path ends in .gz.
+// Closing it flushes and closes the gzip stream before the file.
+func createOutput(path string, compress bool) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if !compress && !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	return &gzipFile{gzip.NewWriter(f), f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Writer.Close(), g.f.Close())
+}
+
+// filterByMean returns the results with a mean, rounded like in the output, above and below the given
+// temperatures in tenths of a degree. A nil bound isn't checked.
+func filterByMean(results []*measurement, above, below *int64) []*measurement {
+	var res []*measurement
+	for _, m := range results {
+		mean := m.mean()
+		if above != nil && mean <= float64(*above)/10. {
+			continue
+		}
+		if below != nil && mean >= float64(*below)/10. {
+			continue
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+// splitBySign partitions the results into the stations with a negative mean and the others, keeping their order.
+func splitBySign(results []*measurement) (cold, warm []*measurement) {
+	for _, m := range results {
+		if m.sum < 0 {
+			cold = append(cold, m)
+		} else {
+			warm = append(warm, m)
+		}
+	}
+	return cold, warm
+}
+
+// writeSplitBySign writes the results in a [cold] section with the stations with a negative mean
+// and a [warm] section with the others, each in the configured format.
+func (o *output) writeSplitBySign(w io.Writer, results []*measurement) error {
+	cold, warm := splitBySign(results)
+	for _, section := range []struct {
+		name    string
+		results []*measurement
+	}{{"cold", cold}, {"warm", warm}} {
+		if _, err := fmt.Fprintf(w, "[%s]%s", section.name, o.eol); err != nil {
+			return err
+		}
+		if err := o.write(w, section.results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
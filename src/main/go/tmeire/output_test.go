@@ -0,0 +1,441 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHamburg;34.2\nBulawayo;10.1\n"))
+
+	cols, err := parseColumns("station,max,count,stddev,min")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := writeCSV(&sb, sortedResults(data), cols, "\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "station,max,count,stddev,min\n" +
+		"Bulawayo,10.1,2,0.6,8.9\n" +
+		"Hamburg,34.2,3,15.4,-3.4\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong csv output, expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	for _, tc := range []struct {
+		columns  string
+		name     string
+		expected string
+	}{
+		{columns: "station,min,mean,max", name: "sem", expected: "station,min,mean,max,sem"},
+		{columns: "station,sem,mean", name: "sem", expected: "station,sem,mean"},
+		{columns: "station,median", name: "median", expected: "station,median"},
+		{columns: "station,mean", name: "distinct", expected: "station,mean,distinct"},
+	} {
+		if got := addColumn(tc.columns, tc.name); got != tc.expected {
+			t.Errorf("Wrong columns adding %s to %s, expected: %s, got: %s", tc.name, tc.columns, tc.expected, got)
+		}
+	}
+}
+
+func TestParseColumnsUnknown(t *testing.T) {
+	if _, err := parseColumns("station,mode"); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+}
+
+func TestCollatedOutput(t *testing.T) {
+	data := New()
+	process(data, []byte("Zürich;1.0\nÅrhus;2.0\nabha;3.0\nÉvora;4.0\nEdinburgh;5.0\nZagreb;6.0\n"))
+
+	cols, err := parseColumns("station")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bytesOrder strings.Builder
+	if err := (&output{format: "csv", columns: cols, eol: "\n"}).write(&bytesOrder, sortedResults(data)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "station\nEdinburgh\nZagreb\nZürich\nabha\nÅrhus\nÉvora\n"; bytesOrder.String() != expected {
+		t.Errorf("Wrong byte order, expected:\n%s\ngot:\n%s", expected, bytesOrder.String())
+	}
+
+	for _, tc := range []struct {
+		locale   string
+		expected string
+	}{
+		{locale: "en", expected: "station\nabha\nÅrhus\nEdinburgh\nÉvora\nZagreb\nZürich\n"},
+		// Danish sorts Å after Z
+		{locale: "da", expected: "station\nabha\nEdinburgh\nÉvora\nZagreb\nZürich\nÅrhus\n"},
+	} {
+		collator, err := newCollator(tc.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sb strings.Builder
+		if err := (&output{format: "csv", columns: cols, eol: "\n", collator: collator}).write(&sb, sortedResults(data)); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != tc.expected {
+			t.Errorf("Wrong %s collation, expected:\n%s\ngot:\n%s", tc.locale, tc.expected, sb.String())
+		}
+	}
+}
+
+func TestWriteNDJSONHistogram(t *testing.T) {
+	data := New()
+	p := &parser{histogram: true}
+	p.process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHamburg;12.0\nBulawayo;10.1\nHamburg;34.2\n"))
+
+	var sb strings.Builder
+	if err := writeNDJSON(&sb, sortedResults(data), false, "\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per station, got:\n%s", sb.String())
+	}
+	for _, line := range lines {
+		var jm jsonMeasurement
+		if err := json.Unmarshal([]byte(line), &jm); err != nil {
+			t.Fatal(err)
+		}
+
+		var total int64
+		for _, bin := range jm.Histogram {
+			total += int64(bin.Count)
+		}
+		if total != jm.Count {
+			t.Errorf("Histogram of %s sums to %d, expected: %d", jm.Station, total, jm.Count)
+		}
+	}
+
+	expected := `{"station":"Hamburg","min":-3.4,"mean":13.7,"max":34.2,"count":4,"histogram":[{"value":-3.4,"count":1},{"value":12.0,"count":2},{"value":34.2,"count":1}]}`
+	if lines[1] != expected {
+		t.Errorf("Wrong ndjson line, expected:\n%s\ngot:\n%s", expected, lines[1])
+	}
+}
+
+func TestOutputEOL(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\n"))
+
+	cols, err := parseColumns("station,min")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		format   string
+		expected string
+	}{
+		{format: "text", expected: "{Bulawayo=8.9/8.9/8.9, Hamburg=12.0/12.0/12.0, }\r\n"},
+		{format: "csv", expected: "station,min\r\nBulawayo,8.9\r\nHamburg,12.0\r\n"},
+		{format: "ndjson", expected: `{"station":"Bulawayo","min":8.9,"mean":8.9,"max":8.9,"count":1}` + "\r\n" +
+			`{"station":"Hamburg","min":12.0,"mean":12.0,"max":12.0,"count":1}` + "\r\n"},
+	} {
+		var sb strings.Builder
+		out := &output{format: tc.format, columns: cols, eol: lineTerminators["crlf"], precision: precision{1, 1, 1}}
+		if err := out.write(&sb, sortedResults(data)); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != tc.expected {
+			t.Errorf("Wrong %s output, expected: %q, got: %q", tc.format, tc.expected, sb.String())
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nHamburg;-3.4\nHamburg;34.2\n"))
+
+	for _, tc := range []struct {
+		flag     string
+		expected string
+	}{
+		{flag: "min=1,mean=1,max=1", expected: "{Hamburg=-3.4/14.3/34.2, }\n"},
+		{flag: "mean=2", expected: "{Hamburg=-3.4/14.27/34.2, }\n"},
+		{flag: "min=2,mean=3,max=1", expected: "{Hamburg=-3.40/14.267/34.2, }\n"},
+	} {
+		prec := precision{1, 1, 1}
+		if err := prec.Set(tc.flag); err != nil {
+			t.Fatal(err)
+		}
+
+		var sb strings.Builder
+		out := &output{format: "text", eol: "\n", precision: prec}
+		if err := out.write(&sb, sortedResults(data)); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != tc.expected {
+			t.Errorf("Wrong output for %s, expected: %q, got: %q", tc.flag, tc.expected, sb.String())
+		}
+	}
+
+	var prec precision
+	if err := prec.Set("median=2"); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestTenthsInt(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nHamburg;-3.4\nHamburg;34.2\nBulawayo;8.9\n"))
+	results := sortedResults(data)
+
+	var sb strings.Builder
+	out := &output{format: "text", eol: "\n", tenthsInt: true}
+	if err := out.write(&sb, results); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected strings.Builder
+	expected.WriteString("{")
+	for _, m := range results {
+		fmt.Fprintf(&expected, "%s=%d/%d/%d, ", m.name, m.min, m.meanTenths(), m.max)
+	}
+	expected.WriteString("}\n")
+	if sb.String() != expected.String() || sb.String() != "{Bulawayo=89/89/89, Hamburg=-34/143/342, }\n" {
+		t.Errorf("Wrong tenths output, expected: %q, got: %q", expected.String(), sb.String())
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	data := New()
+	process(data, []byte("Rio;12.0\nRio;-3.4\nPetropavlovsk-Kamchatsky;-12.5\nİzmir;17.9\nHo Chi Minh City;8.9\n"))
+
+	var sb strings.Builder
+	out := &output{format: "table", eol: "\n"}
+	if err := out.write(&sb, sortedResults(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "" +
+		"station                     min   mean    max\n" +
+		"Ho Chi Minh City            8.9    8.9    8.9\n" +
+		"Petropavlovsk-Kamchatsky  -12.5  -12.5  -12.5\n" +
+		"Rio                        -3.4    4.3   12.0\n" +
+		"İzmir                      17.9   17.9   17.9\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong table, expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestGzipOutput(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"))
+	results := sortedResults(data)
+	out := &output{format: "text", eol: "\n", precision: precision{1, 1, 1}}
+
+	var expected strings.Builder
+	if err := out.write(&expected, results); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "out.txt.gz")
+	w, err := createOutput(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.write(w, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != expected.String() {
+		t.Errorf("Wrong decompressed output, expected: %q, got: %q", expected.String(), got)
+	}
+}
+
+func TestSplitBySign(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nYakutsk;-30.2\nHamburg;-13.4\nOslo;-0.1\nOslo;0.1\nVostok;-55.0\nPalembang;38.8\n"))
+
+	var sb strings.Builder
+	out := &output{format: "text", eol: "\n", precision: precision{1, 1, 1}}
+	if err := out.writeSplitBySign(&sb, sortedResults(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[cold]\n{Hamburg=-13.4/-0.7/12.0, Vostok=-55.0/-55.0/-55.0, Yakutsk=-30.2/-30.2/-30.2, }\n" +
+		"[warm]\n{Oslo=-0.1/0.0/0.1, Palembang=38.8/38.8/38.8, }\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong split output, expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestFilterByMean(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nHamburg;-3.4\nPalembang;38.8\nBulawayo;8.9\nYakutsk;-30.2\nOslo;10.0\n"))
+	results := sortedResults(data)
+
+	names := func(results []*measurement) []string {
+		var res []string
+		for _, m := range results {
+			res = append(res, string(m.name))
+		}
+		return res
+	}
+	above, below := int64(43), int64(100)
+	if got := names(filterByMean(results, &above, nil)); !slices.Equal(got, []string{"Bulawayo", "Oslo", "Palembang"}) {
+		t.Errorf("Wrong stations with a mean above 4.3, got: %v", got)
+	}
+	if got := names(filterByMean(results, nil, &below)); !slices.Equal(got, []string{"Bulawayo", "Hamburg", "Yakutsk"}) {
+		t.Errorf("Wrong stations with a mean below 10.0, got: %v", got)
+	}
+	if got := names(filterByMean(results, &above, &below)); !slices.Equal(got, []string{"Bulawayo"}) {
+		t.Errorf("Wrong stations with a mean between 4.3 and 10.0, got: %v", got)
+	}
+
+	var sb strings.Builder
+	out := &output{format: "text", eol: "\n", precision: precision{1, 1, 1}, meanAbove: &above}
+	if err := out.write(&sb, results); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{Bulawayo=8.9/8.9/8.9, Oslo=10.0/10.0/10.0, Palembang=38.8/38.8/38.8, }\n"; sb.String() != expected {
+		t.Errorf("Wrong filtered output, expected: %q, got: %q", expected, sb.String())
+	}
+}
+
+func TestUnitsHeader(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"))
+	cols, err := parseColumns("station,min,mean,max")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		format string
+		comma  rune
+	}{{"csv", ','}, {"tsv", '\t'}} {
+		var sb strings.Builder
+		out := &output{format: tc.format, columns: cols, eol: "\n", header: true}
+		if err := out.write(&sb, sortedResults(data)); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sb.String(), unitsHeader+"\n") {
+			t.Errorf("Expected the %s output to start with the units header, got:\n%s", tc.format, sb.String())
+		}
+
+		r := csv.NewReader(strings.NewReader(sb.String()))
+		r.Comma, r.Comment = tc.comma, '#'
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]string{{"station", "min", "mean", "max"}, {"Bulawayo", "8.9", "8.9", "8.9"}, {"Hamburg", "-3.4", "4.3", "12.0"}}
+		if !slices.EqualFunc(rows, expected, slices.Equal[[]string]) {
+			t.Errorf("Wrong %s rows after skipping the header, expected: %v, got: %v", tc.format, expected, rows)
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nPalembang;21.3\n"))
+	results := sortedResults(data)
+	for _, m := range results {
+		if m.spread() != m.max-m.min {
+			t.Errorf("Wrong spread of %s, expected: %d, got: %d", m.name, m.max-m.min, m.spread())
+		}
+	}
+
+	cols, err := parseColumns("station,min,max,spread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		format   string
+		expected string
+	}{
+		{format: "text", expected: "{Bulawayo=8.9/8.9/8.9/0.0, Hamburg=-3.4/4.3/12.0/15.4, Palembang=21.3/30.1/38.8/17.5, }\n"},
+		{format: "csv", expected: "station,min,max,spread\nBulawayo,8.9,8.9,0.0\nHamburg,-3.4,12.0,15.4\nPalembang,21.3,38.8,17.5\n"},
+		{format: "compact", expected: "Bulawayo:8.9/8.9/8.9/0.0 Hamburg:-3.4/4.3/12.0/15.4 Palembang:21.3/30.1/38.8/17.5\n"},
+	} {
+		var sb strings.Builder
+		out := &output{format: tc.format, columns: cols, eol: "\n", precision: precision{min: 1, mean: 1, max: 1}, spread: true}
+		if err := out.write(&sb, results); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != tc.expected {
+			t.Errorf("Wrong %s output with the spread, expected: %q, got: %q", tc.format, tc.expected, sb.String())
+		}
+	}
+}
+
+func TestWriteCompact(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nİzmir;17.9\n"))
+
+	var sb strings.Builder
+	out := &output{format: "compact", eol: "\n"}
+	if err := out.write(&sb, sortedResults(data)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Bulawayo:8.9/8.9/8.9 Hamburg:-3.4/4.3/12.0 İzmir:17.9/17.9/17.9\n"
+	if sb.String() != expected {
+		t.Errorf("Wrong compact output, expected: %q, got: %q", expected, sb.String())
+	}
+}
+
+func TestHistogramColumnsWithoutFlags(t *testing.T) {
+	for _, tc := range []struct {
+		columns   string
+		histogram bool
+	}{
+		{columns: "station,min,mean,max", histogram: false},
+		{columns: "station,median", histogram: true},
+		{columns: "distinct,count", histogram: true},
+	} {
+		cols, err := parseColumns(tc.columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := needsHistogram(cols); got != tc.histogram {
+			t.Errorf("Wrong histogram need of the columns %s, expected: %v, got: %v", tc.columns, tc.histogram, got)
+		}
+	}
+
+	// Selecting the columns is enough to track the histograms
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte("Hamburg;12.0\nHamburg;13.0\nHamburg;-3.0\nAbha;18.3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := string(runCalc(t, nil, "-format", "csv", "-csv-columns", "station,median,distinct", filename))
+	if expected := "station,median,distinct\nAbha,18.3,1\nHamburg,12.0,3\n"; got != expected {
+		t.Errorf("Wrong histogram columns, expected: %q, got: %q", expected, got)
+	}
+}
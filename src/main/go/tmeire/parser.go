@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// parser handles the input format variants. Without any options set it uses the fast path in process,
+// otherwise blocks are parsed line by line.
+type parser struct {
+	// pairedLines reads the station name and its temperature from two consecutive lines.
+	pairedLines bool
+
+	// preaggregated reads name;sum;count rows, with the sum in degrees.
+	preaggregated bool
+
+	// alertAbove calls onAlert for every reading above it.
+	alertAbove *int64
+	onAlert    func(name []byte, temperature int64)
+
+	// clamp limits every reading to [clamp[0], clamp[1]], so a single bogus reading can't become the min or max.
+	clamp *[2]int64
+
+	// histogram tracks a histogram of the readings per station.
+	histogram bool
+
+	// flushBytes makes every worker hand its results to the collector and start over
+	// after processing this many bytes, instead of only once all blocks are processed.
+	flushBytes int
+
+	// valueColumn picks the temperature from the fields after the station name, counting from 1,
+	// for rows with several values like name;temperature;humidity.
+	valueColumn int
+
+	// lenient accepts temperatures with leading zeros or a plus sign, like +05.0.
+	lenient bool
+
+	// throughput counts the rows and bytes processed, if set.
+	throughput *throughput
+
+	// deterministic sends block i to worker i % workers, instead of to the first idle worker.
+	deterministic bool
+
+	// decryptKey decrypts the input with AES-CTR before decompressing it, if set.
+	decryptKey []byte
+
+	// gzipIndex is the path of the index of the gzip members of the input, decompressed in parallel if set.
+	gzipIndex string
+
+	// skipBytes discards a header of this many bytes before the first record, up to the end of its line.
+	skipBytes int64
+
+	// checkSorted fails the aggregation on the first station name sorting before the one on the line before it.
+	checkSorted bool
+
+	// insertionOrder numbers the stations by their first line, for --sort=insertion.
+	// The blocks are assigned round robin like with deterministic, so every worker knows the number of its blocks.
+	insertionOrder bool
+
+	// emptyName is what to do with lines like ;12.3, with a delimiter but no station name.
+	emptyName emptyNamePolicy
+
+	// nfc normalizes the station names to Unicode NFC, so the composed and decomposed forms of a name are one station.
+	nfc bool
+
+	// tenthsInput reads the temperatures as integer tenths of a degree without a decimal point, like 183 for 18.3.
+	tenthsInput bool
+
+	// valueFirst reads temperature;name lines, with the value before the station name.
+	valueFirst bool
+
+	// ties counts the readings tying or beating the min and max of their station, if set.
+	ties *tieStats
+
+	// ndjson reads JSON objects with station and temp fields, one per line.
+	ndjson bool
+
+	// cache loads the results of the blocks parsed by an earlier run, and stores the others, if set.
+	cache *blockCache
+
+	// onError is called for every malformed row, see Options.OnError. The rows are checked while reading
+	// and parsed with processLines, which skips the same rows.
+	onError func(line int, raw []byte, err error)
+
+	// ctx stops reading the input once it's done, if set.
+	ctx context.Context
+}
+
+// recordEnd returns the index of the newline ending the last full record in b, or -1 if b has no full record.
+func (p *parser) recordEnd(b []byte) int {
+	end := bytes.LastIndexByte(b, '\n')
+	if !p.pairedLines || end < 0 {
+		return end
+	}
+
+	// A record ends after a temperature line, otherwise the last line is a station name waiting for its value
+	start := bytes.LastIndexByte(b[:end], '\n') + 1
+	if isTemperature(b[start:end]) {
+		return end
+	}
+	return start - 1
+}
+
+// firstRecord returns the first record of the block b, without its line end.
+func (p *parser) firstRecord(b []byte) []byte {
+	end := bytes.IndexByte(b, '\n')
+	if p.pairedLines && end >= 0 {
+		if next := bytes.IndexByte(b[end+1:], '\n'); next >= 0 {
+			end += 1 + next
+		}
+	}
+	if end < 0 {
+		return b
+	}
+	return b[:end]
+}
+
+// lastRecord returns the last record of the block b, which ends with a line end, without it.
+func (p *parser) lastRecord(b []byte) []byte {
+	b = b[:len(b)-1]
+	start := bytes.LastIndexByte(b, '\n') + 1
+	if p.pairedLines && start > 0 {
+		start = bytes.LastIndexByte(b[:start-1], '\n') + 1
+	}
+	return b[start:]
+}
+
+func (p *parser) process(data measurements, b []byte) {
+	switch {
+	case p.pairedLines:
+		p.processPaired(data, b)
+	case p.preaggregated:
+		processPreaggregated(data, b)
+	case p.ndjson:
+		p.processNDJSON(data, b)
+	case p.alertAbove != nil || p.histogram || p.lenient || p.clamp != nil || p.valueColumn > 0 || p.emptyName == emptyNameKeep || p.valueFirst || p.ties != nil || p.tenthsInput || p.nfc || p.onError != nil:
+		p.processLines(data, b)
+	default:
+		process(data, b)
+	}
+}
+
+// add adds a single reading, applying the options that act on individual readings.
+func (p *parser) add(data measurements, name []byte, temperature int64) *measurement {
+	if p.nfc && !norm.NFC.IsNormal(name) {
+		name = norm.NFC.Bytes(name)
+	}
+	if p.clamp != nil {
+		temperature = min(max(temperature, p.clamp[0]), p.clamp[1])
+	}
+	if p.alertAbove != nil && temperature > *p.alertAbove {
+		p.onAlert(name, temperature)
+	}
+	var m *measurement
+	if p.ties != nil {
+		m = p.ties.add(data, name, temperature)
+	} else {
+		m = data.Add(name, temperature)
+	}
+	if p.histogram {
+		if m.hist == nil {
+			m.hist = newHistogram()
+		}
+		m.hist.Add(temperature)
+	}
+	return m
+}
+
+// processLines is the line by line counterpart of process.
+func (p *parser) processLines(data measurements, b []byte) {
+	p.eachLineReading(b, func(name []byte, temperature int64) {
+		p.add(data, name, temperature)
+	})
+}
+
+// processOrdered is processLines numbering the stations new to data by their first line in b, counting from seq.
+func (p *parser) processOrdered(data measurements, b []byte, seq uint64) {
+	fn := func(name []byte, temperature int64) {
+		if m := p.add(data, name, temperature); m.count == 1 {
+			m.seq = seq
+		}
+		seq++
+	}
+	p.eachLineReading(b, fn)
+}
+
+// eachLineReading calls fn for every valid line in b, using eachReading unless an option needs eachFieldReading.
+func (p *parser) eachLineReading(b []byte, fn func(name []byte, temperature int64)) {
+	if p.lenient || p.valueColumn > 0 || p.emptyName == emptyNameKeep || p.valueFirst || p.tenthsInput {
+		p.eachFieldReading(b, fn)
+		return
+	}
+	eachReading(b, fn)
+}
+
+// eachReading calls fn for every valid name;temperature line in b.
+func eachReading(b []byte, fn func(name []byte, temperature int64)) {
+	for len(b) > 0 {
+		var line []byte
+		line, b, _ = bytes.Cut(b, []byte{'\n'})
+
+		name, temp, ok := bytes.Cut(line, []byte{';'})
+		if !ok || len(name) == 0 || !isTemperature(temp) {
+			continue
+		}
+		fn(name, parseTemperature(temp))
+	}
+}
+
+// eachFieldReading is eachReading for lines with the temperature in the valueColumn field after the name,
+// parsed with parseLenient if lenient is set. Lines without a name are only kept with emptyNameKeep.
+// With valueFirst the temperature comes before the name instead, with tenthsInput it's parsed with parseTenths.
+func (p *parser) eachFieldReading(b []byte, fn func(name []byte, temperature int64)) {
+	for len(b) > 0 {
+		var line []byte
+		line, b, _ = bytes.Cut(b, []byte{'\n'})
+
+		name, temp, ok := bytes.Cut(line, []byte{';'})
+		if p.valueFirst {
+			name, temp = temp, name
+		}
+		if !ok || (len(name) == 0 && p.emptyName != emptyNameKeep) {
+			continue
+		}
+		if p.valueColumn > 0 {
+			if temp, ok = field(temp, p.valueColumn); !ok {
+				continue
+			}
+		}
+
+		if p.tenthsInput {
+			if t, ok := parseTenths(temp); ok {
+				fn(name, t)
+			}
+		} else if p.lenient {
+			t, ok := parseLenient(temp)
+			if ok {
+				fn(name, t)
+			}
+		} else if isTemperature(temp) {
+			fn(name, parseTemperature(temp))
+		}
+	}
+}
+
+// field returns the nth, counting from 1, of the ';' separated fields.
+func field(fields []byte, n int) ([]byte, bool) {
+	for i := 1; i < n; i++ {
+		var ok bool
+		if _, fields, ok = bytes.Cut(fields, []byte{';'}); !ok {
+			return nil, false
+		}
+	}
+	f, _, _ := bytes.Cut(fields, []byte{';'})
+	return f, true
+}
+
+// parseLenient parses a temperature into tenths, allowing a plus sign and leading zeros in the integer part,
+// like +5.0, 05.0 or -007.3, and more than one fractional digit, rounded by parseDecimal, like 5.25.
+func parseLenient(b []byte) (int64, bool) {
+	if len(b) > 0 && b[0] == '+' {
+		b = b[1:]
+		if len(b) > 0 && b[0] == '-' {
+			return 0, false
+		}
+	}
+	return parseDecimal(b)
+}
+
+// processPaired parses records where the station name and its temperature are on consecutive lines.
+func (p *parser) processPaired(data measurements, b []byte) {
+	var name []byte
+	for len(b) > 0 {
+		var line []byte
+		line, b, _ = bytes.Cut(b, []byte{'\n'})
+
+		if name == nil {
+			if len(line) > 0 {
+				name = line
+			}
+			continue
+		}
+		if isTemperature(line) {
+			p.add(data, name, parseTemperature(line))
+		}
+		name = nil
+	}
+}
+
+// processPreaggregated parses name;sum;count rows and folds them in as count readings.
+func processPreaggregated(data measurements, b []byte) {
+	for len(b) > 0 {
+		var line []byte
+		line, b, _ = bytes.Cut(b, []byte{'\n'})
+
+		name, rest, ok := bytes.Cut(line, []byte{';'})
+		if !ok || len(name) == 0 {
+			continue
+		}
+		sumField, countField, ok := bytes.Cut(rest, []byte{';'})
+		if !ok {
+			continue
+		}
+		sum, ok := parseFixed(sumField)
+		if !ok {
+			continue
+		}
+		count, err := strconv.ParseInt(string(countField), 10, 64)
+		if err != nil || count <= 0 {
+			continue
+		}
+		data.AddWeighted(name, sum, count)
+	}
+}
+
+// parseTenths parses an integer number of tenths of a degree, like -183 for -18.3.
+func parseTenths(b []byte) (int64, bool) {
+	neg := len(b) > 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		return -n, true
+	}
+	return n, true
+}
+
+// parseFixed parses a number with any number of integer digits and a single fractional digit into tenths.
+func parseFixed(b []byte) (int64, bool) {
+	neg := len(b) > 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	if len(b) < 3 || b[len(b)-2] != '.' {
+		return 0, false
+	}
+
+	var n int64
+	for i, c := range b {
+		if i == len(b)-2 {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		return -n, true
+	}
+	return n, true
+}
+
+// isTemperature reports whether b is a temperature with a single fractional digit, as expected by parseTemperature.
+func isTemperature(b []byte) bool {
+	if len(b) > 0 && b[0] == '-' {
+		b = b[1:]
+	}
+	if len(b) < 3 || len(b) > 4 || b[len(b)-2] != '.' {
+		return false
+	}
+	for i, c := range b {
+		if i != len(b)-2 && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseClamp parses a MIN:MAX range in degrees into tenths of a degree.
+func parseClamp(s string) (*[2]int64, error) {
+	lo, hi, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid range %q, expected MIN:MAX", s)
+	}
+	var r [2]int64
+	var err error
+	if r[0], err = parseDegrees(lo); err != nil {
+		return nil, err
+	}
+	if r[1], err = parseDegrees(hi); err != nil {
+		return nil, err
+	}
+	if r[0] > r[1] {
+		return nil, fmt.Errorf("invalid range %q, the min is above the max", s)
+	}
+	return &r, nil
+}
+
+// parseDegrees parses a temperature in degrees, like a flag value, into tenths of a degree.
+func parseDegrees(s string) (int64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(v * 10)), nil
+}
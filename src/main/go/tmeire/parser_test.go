@@ -0,0 +1,237 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPairedLines(t *testing.T) {
+	input := "Hamburg\n12.0\nBulawayo\n8.9\nHamburg\n-3.4\nPalembang\n38.8\nBulawayo\n-10.1\nHamburg\n34.2\n"
+
+	expected := New()
+	process(expected, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-10.1\nHamburg;34.2\n"))
+
+	data, err := collectData(strings.NewReader(input), 24, 2, &parser{pairedLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := data.Stats(); !maps.Equal(got, expected.Stats()) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), got)
+	}
+}
+
+func TestAlertAbove(t *testing.T) {
+	type alert struct {
+		name        string
+		temperature int64
+	}
+	var alerts []alert
+
+	threshold := int64(300)
+	p := &parser{alertAbove: &threshold, onAlert: func(name []byte, temperature int64) {
+		alerts = append(alerts, alert{string(name), temperature})
+	}}
+
+	data := New()
+	p.process(data, []byte("Hamburg;12.0\nPalembang;38.8\nBulawayo;30.0\nHamburg;30.1\n"))
+
+	expected := []alert{{"Palembang", 388}, {"Hamburg", 301}}
+	if !slices.Equal(alerts, expected) {
+		t.Errorf("Wrong alerts, expected: %v, got: %v", expected, alerts)
+	}
+	if got := data.Stats()["Hamburg"].Count; got != 2 {
+		t.Errorf("Alerting readings should still be aggregated, expected 2 readings, got: %d", got)
+	}
+}
+
+func TestPreaggregated(t *testing.T) {
+	raw := New()
+	process(raw, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-10.1\nHamburg;34.2\n"))
+
+	data := New()
+	p := &parser{preaggregated: true}
+	p.process(data, []byte("Hamburg;8.6;2\nBulawayo;-1.2;2\nPalembang;38.8;1\nHamburg;34.2;1\n"))
+
+	expected, got := raw.Stats(), data.Stats()
+	if len(got) != len(expected) {
+		t.Fatalf("Wrong stations, expected: %v, got: %v", expected, got)
+	}
+	for name, e := range expected {
+		g := got[name]
+		if g.Sum != e.Sum || g.Count != e.Count || g.Mean() != e.Mean() {
+			t.Errorf("Wrong aggregation of %s, expected: %v, got: %v", name, e, g)
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected int64
+		ok       bool
+	}{
+		{input: "5.0", expected: 50, ok: true},
+		{input: "05.0", expected: 50, ok: true},
+		{input: "+5.0", expected: 50, ok: true},
+		{input: "007.3", expected: 73, ok: true},
+		{input: "+007.3", expected: 73, ok: true},
+		{input: "-05.1", expected: -51, ok: true},
+		{input: "-0.0", expected: 0, ok: true},
+		{input: "+-5.0"},
+		{input: "++5.0"},
+		{input: "+.5"},
+		{input: "5"},
+	} {
+		got, ok := parseLenient([]byte(tc.input))
+		if ok != tc.ok || got != tc.expected {
+			t.Errorf("Wrong lenient parse of %q, expected: %d %v, got: %d %v", tc.input, tc.expected, tc.ok, got, ok)
+		}
+	}
+
+	input := []byte("Foo;05.0\nFoo;+5.0\nFoo;007.3\nBar;-1.0\n")
+	data := New()
+	(&parser{lenient: true}).process(data, input)
+	expected := map[string]Stats{
+		"Foo": {Min: 50, Max: 73, Sum: 173, Count: 3},
+		"Bar": {Min: -10, Max: -10, Sum: -10, Count: 1},
+	}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong lenient aggregation, expected: %v, got: %v", expected, data.Stats())
+	}
+}
+
+func TestClamp(t *testing.T) {
+	clamp, err := parseClamp("-50:50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []byte("Hamburg;12.0\nHamburg;-99.9\nHamburg;-3.4\nBulawayo;8.9\nBulawayo;60.0\n")
+	data := New()
+	(&parser{clamp: clamp}).process(data, input)
+	expected := map[string]Stats{
+		"Hamburg":  {Min: -500, Max: 120, Sum: -414, Count: 3},
+		"Bulawayo": {Min: 89, Max: 500, Sum: 589, Count: 2},
+	}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong clamped aggregation, expected: %v, got: %v", expected, data.Stats())
+	}
+
+	// Readings beyond the strict format only parse with --lenient
+	data = New()
+	(&parser{clamp: clamp, lenient: true}).process(data, []byte("Hamburg;12.0\nHamburg;-999.9\n"))
+	if got := data.Stats()["Hamburg"]; got.Min != -500 || got.Max != 120 {
+		t.Errorf("Wrong clamped lenient aggregation, got: %v", got)
+	}
+
+	for _, s := range []string{"10", "1:x", "5:-5"} {
+		if _, err := parseClamp(s); err == nil {
+			t.Errorf("Expected an error for the range %q", s)
+		}
+	}
+}
+
+func TestValueColumn(t *testing.T) {
+	input := []byte("Hamburg;12.0;81.5;1013.2\nBulawayo;8.9;40.0;1009.9\nHamburg;-3.4;90.1;1020.0\nBulawayo;10.1\n")
+
+	for _, tc := range []struct {
+		column   int
+		expected map[string]Stats
+	}{
+		{column: 1, expected: map[string]Stats{
+			"Hamburg":  {Min: -34, Max: 120, Sum: 86, Count: 2},
+			"Bulawayo": {Min: 89, Max: 101, Sum: 190, Count: 2},
+		}},
+		{column: 2, expected: map[string]Stats{
+			"Hamburg":  {Min: 815, Max: 901, Sum: 1716, Count: 2},
+			"Bulawayo": {Min: 400, Max: 400, Sum: 400, Count: 1},
+		}},
+	} {
+		data := New()
+		(&parser{valueColumn: tc.column}).process(data, input)
+		if !maps.Equal(data.Stats(), tc.expected) {
+			t.Errorf("Wrong aggregation of column %d, expected: %v, got: %v", tc.column, tc.expected, data.Stats())
+		}
+	}
+
+	// The pressure only fits the lenient format
+	data := New()
+	(&parser{valueColumn: 3, lenient: true}).process(data, input)
+	if got := data.Stats()["Hamburg"]; got.Min != 10132 || got.Max != 10200 {
+		t.Errorf("Wrong aggregation of column 3, got: %v", got)
+	}
+}
+
+func TestValueFirst(t *testing.T) {
+	input := []byte("12.0;Hamburg\n8.9;Bulawayo\n\nHamburg;1.0\n-3.4;Hamburg\n;Bulawayo\n10.1;Bulawayo\n")
+	expected := map[string]Stats{
+		"Hamburg":  {Min: -34, Max: 120, Sum: 86, Count: 2},
+		"Bulawayo": {Min: 89, Max: 101, Sum: 190, Count: 2},
+	}
+
+	data := New()
+	(&parser{valueFirst: true}).process(data, input)
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation of value first lines, expected: %v, got: %v", expected, data.Stats())
+	}
+}
+
+func TestTenthsInput(t *testing.T) {
+	input := []byte("Abha;183\nHamburg;120\nAbha;-5\nHamburg;-34\nHamburg;12.0\nAbha;\nBulawayo;0\n")
+	expected := map[string]Stats{
+		"Abha":     {Min: -5, Max: 183, Sum: 178, Count: 2},
+		"Hamburg":  {Min: -34, Max: 120, Sum: 86, Count: 2},
+		"Bulawayo": {Min: 0, Max: 0, Sum: 0, Count: 1},
+	}
+
+	data := New()
+	(&parser{tenthsInput: true}).process(data, input)
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation of tenths input, expected: %v, got: %v", expected, data.Stats())
+	}
+
+	var sb strings.Builder
+	printMeasurements(&sb, sortedResults(data), precision{min: 1, mean: 1, max: 1}, false, "\n")
+	if out := "{Abha=-0.5/8.9/18.3, Bulawayo=0.0/0.0/0.0, Hamburg=-3.4/4.3/12.0, }\n"; sb.String() != out {
+		t.Errorf("Wrong output of tenths input, expected: %q, got: %q", out, sb.String())
+	}
+}
+
+func TestNFC(t *testing.T) {
+	// São Paulo with a precomposed ã and with an a followed by a combining tilde
+	composed, decomposed := "S\u00e3o Paulo", "Sa\u0303o Paulo"
+	input := []byte(composed + ";21.4\n" + decomposed + ";18.0\nHamburg;12.0\n" + decomposed + ";25.2\n")
+
+	data := New()
+	(&parser{}).process(data, input)
+	if got := len(data.Stats()); got != 3 {
+		t.Errorf("Expected the two encodings to be separate stations without normalizing, got %d stations", got)
+	}
+
+	data = New()
+	(&parser{nfc: true}).process(data, input)
+	expected := map[string]Stats{
+		composed:  {Min: 180, Max: 252, Sum: 646, Count: 3},
+		"Hamburg": {Min: 120, Max: 120, Sum: 120, Count: 1},
+	}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation of the normalized names, expected: %v, got: %v", expected, data.Stats())
+	}
+}
+
+func TestBlockRecords(t *testing.T) {
+	for _, tc := range []struct {
+		p           *parser
+		block       string
+		first, last string
+	}{
+		{p: &parser{}, block: "Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\n", first: "Hamburg;12.0", last: "Palembang;38.8"},
+		{p: &parser{}, block: "Hamburg;12.0\n", first: "Hamburg;12.0", last: "Hamburg;12.0"},
+		{p: &parser{pairedLines: true}, block: "Hamburg\n12.0\nBulawayo\n8.9\n", first: "Hamburg\n12.0", last: "Bulawayo\n8.9"},
+	} {
+		if first, last := tc.p.firstRecord([]byte(tc.block)), tc.p.lastRecord([]byte(tc.block)); string(first) != tc.first || string(last) != tc.last {
+			t.Errorf("Wrong records of %q, expected: %q to %q, got: %q to %q", tc.block, tc.first, tc.last, first, last)
+		}
+	}
+}
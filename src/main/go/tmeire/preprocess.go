@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// aggregatePreprocessed aggregates the output of the --preprocess command run with the file as its stdin.
+// The command is split into its arguments by splitCommand, it isn't run by a shell.
+func aggregatePreprocessed(filename, command string, p *parser) (measurements, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty preprocess command")
+	}
+
+	file, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = file
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("preprocess command %q: %w", command, err)
+	}
+
+	data, err := aggregate(stdout, blockSize, p)
+	if err != nil {
+		// Don't leave the command blocked on writing output that is no longer read
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("preprocess command %q: %w", command, err)
+	}
+	return data, nil
+}
+
+// splitCommand splits a command line into its arguments at unquoted spaces. Single quotes keep everything
+// up to the next single quote, double quotes everything up to the next double quote, like
+// awk -F';' '{print $1 ";" $2}'.
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command %q", quote, s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
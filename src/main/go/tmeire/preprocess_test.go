@@ -0,0 +1,55 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, tc := range []struct {
+		command  string
+		expected []string
+	}{
+		{command: "tr , .", expected: []string{"tr", ",", "."}},
+		{command: "  sed  -e 's/ ;/;/'  ", expected: []string{"sed", "-e", "s/ ;/;/"}},
+		{command: `awk -F';' "{print $1}"`, expected: []string{"awk", "-F;", "{print $1}"}},
+		{command: "grep -v ''", expected: []string{"grep", "-v", ""}},
+		{command: "", expected: nil},
+	} {
+		got, err := splitCommand(tc.command)
+		if err != nil || !slices.Equal(got, tc.expected) {
+			t.Errorf("Wrong split of %q, expected: %q, got: %q (%v)", tc.command, tc.expected, got, err)
+		}
+	}
+	if _, err := splitCommand("sed 's/a/b/"); err == nil {
+		t.Error("Expected an error for an unterminated quote")
+	}
+}
+
+func TestPreprocess(t *testing.T) {
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr is not installed")
+	}
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte("Hamburg;12,0\nBulawayo;8,9\nHamburg;-3,4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := aggregatePreprocessed(filename, "tr , .", &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := New()
+	process(expected, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"))
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation of the preprocessed input, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+
+	if _, err := aggregatePreprocessed(filename, "tr --no-such-option", &parser{}); err == nil {
+		t.Error("Expected an error for a failing preprocess command")
+	}
+}
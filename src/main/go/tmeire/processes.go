@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errGzipRanges = errors.New("--processes can't split gzipped input, decompress it first")
+
+// runRange returns the dump of the aggregated bytes [offset, offset+length) of the input, see childRunner.
+type runRange func(offset, length int64) ([]byte, error)
+
+// aggregateProcesses splits filename into n line aligned ranges, aggregates every range with run and
+// merges the dumps. The histograms don't survive a dump, and gzipped input can't be split in ranges.
+func aggregateProcesses(filename string, n int, run runRange) (measurements, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	gzipped, err := startsWithGzip(file)
+	if err == nil && gzipped {
+		err = errGzipRanges
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	bounds, err := splitRanges(file, info.Size(), max(n, 1))
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	dumps := make([][]byte, len(bounds)-1)
+	errs := make([]error, len(dumps))
+	var wg sync.WaitGroup
+	for i := range dumps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dumps[i], errs[i] = run(bounds[i], bounds[i+1]-bounds[i])
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	data := New()
+	for i, b := range dumps {
+		results, err := decodeDump(b, false)
+		if err != nil {
+			return nil, fmt.Errorf("range %d of %s: %w", i, filename, err)
+		}
+		for _, m := range results {
+			data.AddMeasurement(m)
+		}
+	}
+	return data, nil
+}
+
+// aggregateFileRange aggregates the length bytes at offset of filename, which start and end on a line boundary.
+func aggregateFileRange(filename string, offset, length int64, p *parser) (measurements, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := prepareInput(io.NewSectionReader(file, offset, length), p)
+	if err != nil {
+		return nil, err
+	}
+	// No need for blocks larger than the range
+	size := int(min(length, blockSize)) + 1
+	return collectData(r, size, defaultWorkers(), p)
+}
+
+// writeRangeDump is the child side of --processes, it writes the dump of its range to w.
+func writeRangeDump(w io.Writer, filename string, offset, length int64, p *parser) error {
+	data, err := aggregateFileRange(filename, offset, length, p)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeDump(bw, data.Flatten()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// childRunner re-executes the running binary with args, the command line flags without --processes,
+// to aggregate a range of filename in a child process.
+func childRunner(filename string, args []string) runRange {
+	return func(offset, length int64) ([]byte, error) {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		childArgs := append(withoutFlag(args, "processes"),
+			"-offset", strconv.FormatInt(offset, 10), "-length", strconv.FormatInt(length, 10), filename)
+
+		var stdout bytes.Buffer
+		cmd := exec.Command(exe, childArgs...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			// Like the child, exit on the first alert with --alert-exit
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == alertExitCode {
+				os.Exit(alertExitCode)
+			}
+			return nil, fmt.Errorf("aggregating %d bytes at offset %d: %w", length, offset, err)
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// withoutFlag returns the command line flags in args without the flag name and its value.
+func withoutFlag(args []string, name string) []string {
+	var res []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+		if arg == name {
+			// The value is the next argument
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			continue
+		}
+		res = append(res, args[i])
+	}
+	return res
+}
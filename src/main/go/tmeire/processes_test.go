@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAggregateProcesses(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%97, i%100-50, i%10)
+	}
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := aggregateFile(filename, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stddevs := make(map[string]float64)
+	for _, m := range expected.Flatten() {
+		stddevs[string(m.name)] = m.stddev()
+	}
+
+	// Aggregate the ranges in process, standing in for the child processes
+	p := &parser{}
+	run := func(offset, length int64) ([]byte, error) {
+		var buf bytes.Buffer
+		err := writeRangeDump(&buf, filename, offset, length, p)
+		return buf.Bytes(), err
+	}
+	for _, n := range []int{1, 3, 8} {
+		data, err := aggregateProcesses(filename, n, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(data.Stats(), expected.Stats()) {
+			t.Errorf("Wrong aggregation over %d processes, expected: %v, got: %v", n, expected.Stats(), data.Stats())
+		}
+		for _, m := range data.Flatten() {
+			if m.stddev() != stddevs[string(m.name)] {
+				t.Errorf("Wrong stddev of %s over %d processes, expected: %v, got: %v", m.name, n, stddevs[string(m.name)], m.stddev())
+			}
+		}
+	}
+}
+
+func TestAggregateProcessesGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("Hamburg;12.0\nBulawayo;8.9\n"))
+	zw.Close()
+	filename := filepath.Join(t.TempDir(), "measurements.txt.gz")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(offset, length int64) ([]byte, error) {
+		t.Fatal("Expected the gzipped input to be rejected before splitting it")
+		return nil, nil
+	}
+	if _, err := aggregateProcesses(filename, 2, run); !errors.Is(err, errGzipRanges) {
+		t.Errorf("Expected a gzipped input error, got: %v", err)
+	}
+}
+
+func TestWithoutFlag(t *testing.T) {
+	args := []string{"-processes", "4", "--lenient", "--processes=2", "-format", "csv", "-processes-x"}
+	expected := []string{"--lenient", "-format", "csv", "-processes-x"}
+	if got := withoutFlag(args, "processes"); !slices.Equal(got, expected) {
+		t.Errorf("Wrong flags, expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestRangeChildAlert(t *testing.T) {
+	input := "Hamburg;12.0\nPalembang;41.3\nBulawayo;8.9\n"
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runCalc(t, nil, "-alert-above", "40", "-offset", "0", "-length", fmt.Sprint(len(input)), filename)
+	results, err := decodeDump(out, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Wrong number of stations in the range dump, expected: 3, got: %d", len(results))
+	}
+}
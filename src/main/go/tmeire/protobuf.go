@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Protobuf wire types and the field numbers of the Station message in station.proto.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+
+	stationName   = 1
+	stationMin    = 2
+	stationMean   = 3
+	stationMax    = 4
+	stationCount  = 5
+	stationSpread = 6
+)
+
+// writeProtobuf writes a length-delimited Station message for every measurement, with its spread if set.
+func writeProtobuf(w io.Writer, results []*measurement, spread bool) error {
+	bw := bufio.NewWriter(w)
+	var msg, prefix []byte
+	for _, m := range results {
+		msg = appendStation(msg[:0], m, spread)
+		prefix = binary.AppendUvarint(prefix[:0], uint64(len(msg)))
+		bw.Write(prefix)
+		if _, err := bw.Write(msg); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func appendStation(b []byte, m *measurement, spread bool) []byte {
+	b = binary.AppendUvarint(b, stationName<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(m.name)))
+	b = append(b, m.name...)
+	for _, f := range [...]struct {
+		field uint64
+		value float64
+	}{{stationMin, float64(m.min) / 10.}, {stationMean, m.mean()}, {stationMax, float64(m.max) / 10.}} {
+		b = binary.AppendUvarint(b, f.field<<3|wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f.value))
+	}
+	b = binary.AppendUvarint(b, stationCount<<3|wireVarint)
+	b = binary.AppendUvarint(b, uint64(m.count))
+	if spread {
+		b = binary.AppendUvarint(b, stationSpread<<3|wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(float64(m.spread())/10.))
+	}
+	return b
+}
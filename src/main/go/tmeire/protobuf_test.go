@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+type station struct {
+	name           string
+	min, mean, max float64
+	count          int64
+}
+
+// readStations decodes a stream of length-delimited Station messages.
+func readStations(r io.Reader) ([]station, error) {
+	br := bufio.NewReader(r)
+	var res []station
+	for {
+		l, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		msg := make([]byte, l)
+		if _, err := io.ReadFull(br, msg); err != nil {
+			return nil, err
+		}
+
+		var s station
+		for len(msg) > 0 {
+			key, n := binary.Uvarint(msg)
+			msg = msg[n:]
+			switch key & 7 {
+			case wireBytes:
+				l, n := binary.Uvarint(msg)
+				s.name = string(msg[n : n+int(l)])
+				msg = msg[n+int(l):]
+			case wireFixed64:
+				v := math.Float64frombits(binary.LittleEndian.Uint64(msg))
+				msg = msg[8:]
+				switch key >> 3 {
+				case stationMin:
+					s.min = v
+				case stationMean:
+					s.mean = v
+				case stationMax:
+					s.max = v
+				}
+			case wireVarint:
+				v, n := binary.Uvarint(msg)
+				msg = msg[n:]
+				s.count = int64(v)
+			default:
+				return nil, errors.New("unexpected wire type")
+			}
+		}
+		res = append(res, s)
+	}
+}
+
+func TestWriteProtobuf(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\nİzmir;17.9\n"))
+
+	var buf bytes.Buffer
+	out := &output{format: "protobuf"}
+	if err := out.write(&buf, sortedResults(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readStations(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []station{
+		{"Bulawayo", 8.9, 8.9, 8.9, 1},
+		{"Hamburg", -3.4, 4.3, 12.0, 2},
+		{"Palembang", 38.8, 38.8, 38.8, 1},
+		{"İzmir", 17.9, 17.9, 17.9, 1},
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Wrong stations, expected: %v, got: %v", expected, got)
+	}
+}
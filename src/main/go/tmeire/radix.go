@@ -0,0 +1,64 @@
+package main
+
+import "bytes"
+
+// radixSort sorts the results by name with radixSortByName instead of a comparison sort, set by --radix-sort.
+var radixSort = false
+
+// radixCutoff is the number of stations below which a bucket is insertion sorted instead of split further.
+const radixCutoff = 32
+
+// radixSortByName sorts the results in byte order of their names, like sortByName, with an MSD radix sort.
+// It touches every byte of the names at most a few times, which beats comparing the long common prefixes
+// of millions of stations over and over.
+func radixSortByName(results []*measurement) {
+	msdSort(results, make([]*measurement, len(results)), 0)
+}
+
+// msdSort sorts a, whose names all share their first depth bytes, by the byte at depth and then recursively
+// within every bucket. Names ending at depth go first. aux is scratch space as long as a.
+func msdSort(a, aux []*measurement, depth int) {
+	if len(a) < radixCutoff {
+		insertionSortFrom(a, depth)
+		return
+	}
+
+	// Bucket 0 holds the names ending at depth, bucket c+1 the ones with byte c at depth
+	var ends [258]int
+	for _, m := range a {
+		ends[bucketAt(m.name, depth)+1]++
+	}
+	for c := 1; c < len(ends); c++ {
+		ends[c] += ends[c-1]
+	}
+	for _, m := range a {
+		c := bucketAt(m.name, depth)
+		aux[ends[c]] = m
+		ends[c]++
+	}
+	copy(a, aux)
+
+	// Station names are unique, so at most one name ended and bucket 0 needs no sorting
+	for c := 1; c < 257; c++ {
+		if start, end := ends[c-1], ends[c]; end-start > 1 {
+			msdSort(a[start:end], aux[start:end], depth+1)
+		}
+	}
+}
+
+// bucketAt returns the bucket of name at depth for msdSort.
+func bucketAt(name []byte, depth int) int {
+	if depth >= len(name) {
+		return 0
+	}
+	return int(name[depth]) + 1
+}
+
+// insertionSortFrom sorts a by the names from depth on, the bytes before it being the same for all of them.
+func insertionSortFrom(a []*measurement, depth int) {
+	for i := 1; i < len(a); i++ {
+		for j := i; j > 0 && bytes.Compare(a[j].name[depth:], a[j-1].name[depth:]) < 0; j-- {
+			a[j], a[j-1] = a[j-1], a[j]
+		}
+	}
+}
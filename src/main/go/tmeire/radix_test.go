@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// randomStations returns n stations with distinct random names, sharing prefixes and using bytes above 0x7f.
+func randomStations(n int, seed int64) []*measurement {
+	r := rand.New(rand.NewSource(seed))
+	seen := map[string]bool{}
+	results := make([]*measurement, 0, n)
+	for len(results) < n {
+		name := make([]byte, r.Intn(24))
+		for i := range name {
+			// A small alphabet for long common prefixes, and now and then any byte
+			name[i] = "abcAB é"[r.Intn(8)]
+			if r.Intn(10) == 0 {
+				name[i] = byte(r.Intn(256))
+			}
+		}
+		if seen[string(name)] {
+			continue
+		}
+		seen[string(name)] = true
+		results = append(results, &measurement{name: name})
+	}
+	return results
+}
+
+func names(results []*measurement) []string {
+	s := make([]string, len(results))
+	for i, m := range results {
+		s[i] = string(m.name)
+	}
+	return s
+}
+
+func TestRadixSortByName(t *testing.T) {
+	for _, n := range []int{0, 1, 2, radixCutoff - 1, radixCutoff, 1000, 50000} {
+		results := randomStations(n, int64(n))
+		expected := slices.Clone(results)
+		sortByName(expected)
+
+		radixSortByName(results)
+		if got, want := names(results), names(expected); !slices.Equal(got, want) {
+			t.Errorf("Wrong radix sort order of %d stations, expected: %q, got: %q", n, want[:min(n, 10)], got[:min(n, 10)])
+		}
+	}
+
+	// Names that are prefixes of each other
+	results := []*measurement{{name: []byte("abc")}, {name: []byte("ab")}, {name: []byte("")}, {name: []byte("abcd")}, {name: []byte("b")}}
+	radixSortByName(results)
+	if got, want := names(results), []string{"", "ab", "abc", "abcd", "b"}; !slices.Equal(got, want) {
+		t.Errorf("Wrong radix sort order of the prefixes, expected: %q, got: %q", want, got)
+	}
+}
+
+func benchmarkSort(b *testing.B, sort func([]*measurement)) {
+	stations := randomStations(1_000_000, 1)
+	results := make([]*measurement, len(stations))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(results, stations)
+		sort(results)
+	}
+}
+
+func BenchmarkSortByName(b *testing.B) {
+	benchmarkSort(b, sortByName)
+}
+
+func BenchmarkRadixSortByName(b *testing.B) {
+	benchmarkSort(b, radixSortByName)
+}
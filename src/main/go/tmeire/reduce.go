@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// reduceDumps merges the dumps read one after the other from r into data. With --format=dump calc writes
+// a dump of its results to stdout, so shards reduce hierarchically through pipes:
+//
+//	(calc -format dump shard1.txt; calc -format dump shard2.txt) | calc reduce
+//
+// where a reduce with -format dump again feeds the next level. The dumps keep the exact min, max, sum and
+// count of every station, so the result equals aggregating all shards at once.
+func reduceDumps(data measurements, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for len(b) > 0 {
+		var results []*measurement
+		if results, b, err = decodeDumpPrefix(b, false); err != nil {
+			return err
+		}
+		for _, m := range results {
+			data.AddMeasurement(m)
+		}
+	}
+	return nil
+}
+
+// reduceFiles runs reduceDumps on every file, or on stdin if there are none.
+func reduceFiles(data measurements, filenames []string) error {
+	if len(filenames) == 0 {
+		filenames = []string{"-"}
+	}
+	for _, filename := range filenames {
+		var r io.Reader = os.Stdin
+		if filename != "-" {
+			f, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := reduceDumps(data, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCalcHelper runs main with the newline separated arguments in CALC_ARGS, for tests running calc as a process.
+func TestCalcHelper(t *testing.T) {
+	args := os.Getenv("CALC_ARGS")
+	if args == "" {
+		t.Skip("Only runs as a calc process")
+	}
+	os.Args = append([]string{"calc"}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+// runCalc runs calc with args as a process reading stdin and returns its stdout.
+func runCalc(t *testing.T, stdin []byte, args ...string) []byte {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCalcHelper$")
+	cmd.Env = append(os.Environ(), "CALC_ARGS="+strings.Join(args, "\n"))
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Running calc %v: %v", args, err)
+	}
+	return out
+}
+
+// runFailingCalc runs calc with args like runCalc, but expects it to fail and returns its stderr.
+func runFailingCalc(t *testing.T, stdin []byte, args ...string) string {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCalcHelper$")
+	cmd.Env = append(os.Environ(), "CALC_ARGS="+strings.Join(args, "\n"))
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected calc %v to fail", args)
+	}
+	return stderr.String()
+}
+
+func TestReducePipeline(t *testing.T) {
+	dir := t.TempDir()
+	var shards []string
+	var all strings.Builder
+	for s := 0; s < 3; s++ {
+		var sb strings.Builder
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(&sb, "Station%d;%d.%d\n", (i+s*7)%23, (i*s)%100-50, i%10)
+		}
+		all.WriteString(sb.String())
+		shard := filepath.Join(dir, fmt.Sprintf("shard%d.txt", s))
+		if err := os.WriteFile(shard, []byte(sb.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		shards = append(shards, shard)
+	}
+	whole := filepath.Join(dir, "all.txt")
+	if err := os.WriteFile(whole, []byte(all.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// (calc -format dump shard0; calc -format dump shard1) | calc -format dump reduce, then with shard2 | calc reduce
+	var level1 []byte
+	for _, shard := range shards[:2] {
+		level1 = append(level1, runCalc(t, nil, "-format", "dump", shard)...)
+	}
+	level2 := runCalc(t, level1, "-format", "dump", "reduce")
+	level2 = append(level2, runCalc(t, nil, "-format", "dump", shards[2])...)
+	got := runCalc(t, level2, "reduce")
+
+	expected := runCalc(t, nil, whole)
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Wrong reduced results, expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
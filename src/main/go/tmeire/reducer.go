@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"math"
+	"sync"
+)
+
+// State is the per-station state of a Reducer.
+type State any
+
+// A Reducer computes a custom statistic per station. Add folds a single reading in tenths of a degree
+// into the state, Merge combines the states of two workers and Finalize turns the state into the result.
+type Reducer interface {
+	Init() State
+	Add(s State, tenths int64) State
+	Merge(a, b State) State
+	Finalize(s State) float64
+}
+
+// DefaultReducer tracks the min, mean and max like the regular aggregation, and finalizes to the mean in degrees.
+type DefaultReducer struct{}
+
+type defaultState struct {
+	min, max, sum, count int64
+}
+
+func (DefaultReducer) Init() State {
+	return &defaultState{min: math.MaxInt64, max: math.MinInt64}
+}
+
+func (DefaultReducer) Add(s State, tenths int64) State {
+	d := s.(*defaultState)
+	d.min = min(d.min, tenths)
+	d.max = max(d.max, tenths)
+	d.sum += tenths
+	d.count++
+	return d
+}
+
+func (DefaultReducer) Merge(a, b State) State {
+	d, d1 := a.(*defaultState), b.(*defaultState)
+	d.min = min(d.min, d1.min)
+	d.max = max(d.max, d1.max)
+	d.sum += d1.sum
+	d.count += d1.count
+	return d
+}
+
+func (DefaultReducer) Finalize(s State) float64 {
+	d := s.(*defaultState)
+	return rounding.round(float64(d.sum)/float64(d.count)) / 10.
+}
+
+// libraryBlockSize is the block size of the exported aggregation functions, which are
+// typically used on smaller inputs than the challenge file.
+const libraryBlockSize = 4 * 1024 * 1024
+
+// AggregateReducer reads the measurements from r in blocks, reduces them per station with red in workers goroutines
+// and returns the finalized result per station.
+func AggregateReducer(r io.Reader, red Reducer, workers int) (map[string]float64, error) {
+	var wg sync.WaitGroup
+	results := make(chan map[string]State, 1)
+
+	inputs := make(chan []byte)
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			states := make(map[string]State)
+			for input := range inputs {
+				eachReading(input, func(name []byte, temperature int64) {
+					s, ok := states[string(name)]
+					if !ok {
+						s = red.Init()
+					}
+					states[string(name)] = red.Add(s, temperature)
+				})
+			}
+			results <- states
+			wg.Done()
+		}()
+	}
+
+	done := make(chan map[string]State)
+	go func() {
+		states := make(map[string]State)
+		for res := range results {
+			for name, s := range res {
+				if s1, ok := states[name]; ok {
+					s = red.Merge(s1, s)
+				}
+				states[name] = s
+			}
+		}
+		done <- states
+	}()
+
+	err := readBlocks(r, libraryBlockSize, &parser{}, inputs)
+	wg.Wait()
+	close(results)
+
+	res := make(map[string]float64)
+	for name, s := range <-done {
+		res[name] = red.Finalize(s)
+	}
+	return res, err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// geometricMean finalizes to the geometric mean of the readings in degrees, which all need to be positive.
+type geometricMean struct{}
+
+type logState struct {
+	logSum float64
+	count  int
+}
+
+func (geometricMean) Init() State { return &logState{} }
+
+func (geometricMean) Add(s State, tenths int64) State {
+	l := s.(*logState)
+	l.logSum += math.Log(float64(tenths) / 10.)
+	l.count++
+	return l
+}
+
+func (geometricMean) Merge(a, b State) State {
+	l, l1 := a.(*logState), b.(*logState)
+	l.logSum += l1.logSum
+	l.count += l1.count
+	return l
+}
+
+func (geometricMean) Finalize(s State) float64 {
+	l := s.(*logState)
+	return math.Exp(l.logSum / float64(l.count))
+}
+
+func TestAggregateReducer(t *testing.T) {
+	readings := map[string][]float64{
+		"Hamburg":   {12.0, 3.4, 34.2, 8.1},
+		"Bulawayo":  {8.9, 10.1},
+		"Palembang": {38.8},
+	}
+	var sb strings.Builder
+	for i := 0; i < 4; i++ {
+		for name, temps := range readings {
+			if i < len(temps) {
+				sb.WriteString(name + ";" + formatDegrees(temps[i]) + "\n")
+			}
+		}
+	}
+
+	geo, err := AggregateReducer(strings.NewReader(sb.String()), geometricMean{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	means, err := AggregateReducer(strings.NewReader(sb.String()), DefaultReducer{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := New()
+	process(expected, []byte(sb.String()))
+
+	for name, temps := range readings {
+		product := 1.
+		for _, temp := range temps {
+			product *= temp
+		}
+		if e := math.Pow(product, 1/float64(len(temps))); math.Abs(geo[name]-e) > 1e-9 {
+			t.Errorf("Wrong geometric mean for %s, expected: %v, got: %v", name, e, geo[name])
+		}
+		if e := expected.Stats()[name].Mean(); means[name] != e {
+			t.Errorf("Wrong mean for %s, expected: %v, got: %v", name, e, means[name])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runRepairCommand runs calc repair with its own flags in args, rewriting the input file, or - for stdin,
+// in the canonical name;temperature format.
+func runRepairCommand(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	outPath := fs.String("o", "-", "write the repaired rows to `file`, - for stdout")
+	report := fs.Bool("report", false, "print every dropped row to stderr")
+	// The flags can also follow the input, like calc repair in.txt -o clean.txt
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("expected a single input file to repair, got %d", len(files))
+	}
+
+	in, err := openInput(files[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	w := stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var onDrop func(line int, raw []byte)
+	if *report {
+		onDrop = func(line int, raw []byte) {
+			fmt.Fprintf(stderr, "repair: dropped line %d: %q\n", line, raw)
+		}
+	}
+	kept, dropped, err := repair(in, w, onDrop)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "repair: kept %d rows, dropped %d\n", kept, dropped)
+	return nil
+}
+
+// repair copies the rows of r to w in the canonical name;d.d format, calling onDrop, if set,
+// for every row it can't make sense of.
+func repair(r io.Reader, w io.Writer, onDrop func(line int, raw []byte)) (kept, dropped int64, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	bw := bufio.NewWriter(w)
+
+	var out []byte
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Bytes()
+		name, temperature, ok := repairRow(raw)
+		if !ok {
+			// Blank lines, like a trailing one, aren't worth reporting
+			if len(bytes.TrimSpace(raw)) > 0 {
+				dropped++
+				if onDrop != nil {
+					onDrop(line, raw)
+				}
+			}
+			continue
+		}
+
+		out = append(append(out[:0], name...), ';')
+		out = append(appendTenths(out, temperature), '\n')
+		if _, err := bw.Write(out); err != nil {
+			return kept, dropped, err
+		}
+		kept++
+	}
+	if err := scanner.Err(); err != nil {
+		return kept, dropped, err
+	}
+	return kept, dropped, bw.Flush()
+}
+
+// repairRow parses a row leniently: it strips a trailing CR and the spaces around the name and the temperature,
+// and accepts temperatures with a plus sign, leading zeros or a decimal comma. Rows with a temperature
+// that doesn't fit the canonical format, beyond ±99.9, are dropped.
+func repairRow(raw []byte) ([]byte, int64, bool) {
+	name, value, ok := bytes.Cut(bytes.TrimSuffix(raw, []byte{'\r'}), []byte{';'})
+	name, value = bytes.TrimSpace(name), bytes.TrimSpace(value)
+	if !ok || len(name) == 0 {
+		return nil, 0, false
+	}
+	if bytes.IndexByte(value, '.') < 0 {
+		value = bytes.Replace(value, []byte{','}, []byte{'.'}, 1)
+	}
+	t, ok := parseLenient(value)
+	if !ok || t < -999 || t > 999 {
+		return nil, 0, false
+	}
+	return name, t, true
+}
+
+// appendTenths appends a temperature in tenths of a degree in the d.d format of the input.
+func appendTenths(b []byte, t int64) []byte {
+	if t < 0 {
+		b = append(b, '-')
+		t = -t
+	}
+	return fmt.Appendf(b, "%d.%d", t/10, t%10)
+}
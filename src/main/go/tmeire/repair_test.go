@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	messy := "Hamburg;12.0\r\n  Bulawayo ; +8.9 \nPalembang;38,8\nHamburg;-03.4\n;5.0\nbroken\n\nAbha;1e3\nHamburg;123.4\nAbha;+0,5\r\n"
+	canonical := "Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\nAbha;0.5\n"
+
+	var out bytes.Buffer
+	var drops []int
+	kept, dropped, err := repair(strings.NewReader(messy), &out, func(line int, raw []byte) { drops = append(drops, line) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != canonical {
+		t.Errorf("Wrong repaired output, expected: %q, got: %q", canonical, out.String())
+	}
+	if kept != 5 || dropped != 4 || len(drops) != 4 || drops[0] != 5 || drops[3] != 9 {
+		t.Errorf("Wrong repair counts, expected: 5 kept and lines 5, 6, 8 and 9 dropped, got: %d kept and %d dropped, lines %v", kept, dropped, drops)
+	}
+
+	// The repaired rows aggregate like the lenient readings of the valid rows
+	expected := New()
+	(&parser{lenient: true}).process(expected, []byte("Hamburg;12.0\nBulawayo;+8.9\nPalembang;38.8\nHamburg;-03.4\nAbha;+0.5\n"))
+	got := New()
+	process(got, out.Bytes())
+	if !maps.Equal(got.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation of the repaired output, expected: %v, got: %v", expected.Stats(), got.Stats())
+	}
+
+	// Repairing canonical rows doesn't change them
+	var again bytes.Buffer
+	if _, _, err := repair(&out, &again, nil); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != canonical {
+		t.Errorf("Repairing the repaired output changed it, expected: %q, got: %q", canonical, again.String())
+	}
+}
+
+func TestRepairCommand(t *testing.T) {
+	dir := t.TempDir()
+	in, clean := filepath.Join(dir, "in.txt"), filepath.Join(dir, "clean.txt")
+	if err := os.WriteFile(in, []byte("Hamburg ;12,0\r\nbroken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	if err := runRepairCommand([]string{"-report", in, "-o", clean}, nil, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(clean); err != nil || string(got) != "Hamburg;12.0\n" {
+		t.Errorf("Wrong repaired file, expected: %q, got: %q (%v)", "Hamburg;12.0\n", got, err)
+	}
+	if report := "repair: dropped line 2: \"broken\"\nrepair: kept 1 rows, dropped 1\n"; stderr.String() != report {
+		t.Errorf("Wrong repair report, expected: %q, got: %q", report, stderr.String())
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// roundingMode is how the means are rounded to the precision of the output, and how readings with more than
+// one decimal are rounded to tenths, so the min and max are rounded like the mean.
+type roundingMode int
+
+const (
+	// roundHalfAway rounds halves away from zero, like math.Round.
+	roundHalfAway roundingMode = iota
+	// roundHalfEven rounds halves to the even neighbour, like math.RoundToEven, so they don't bias sums.
+	roundHalfEven
+	// roundHalfUp rounds halves towards positive infinity, like Java's Math.round, see javaRound.
+	roundHalfUp
+)
+
+// rounding is the rounding mode set by --rounding.
+var rounding = roundHalfAway
+
+func parseRoundingMode(s string) (roundingMode, error) {
+	switch s {
+	case "half-away":
+		return roundHalfAway, nil
+	case "half-even":
+		return roundHalfEven, nil
+	case "half-up":
+		return roundHalfUp, nil
+	}
+	return 0, fmt.Errorf("unknown rounding mode %q, expected half-away, half-even or half-up", s)
+}
+
+// round rounds v to an integer.
+func (r roundingMode) round(v float64) float64 {
+	switch r {
+	case roundHalfEven:
+		return math.RoundToEven(v)
+	case roundHalfUp:
+		return javaRound(v)
+	}
+	return math.Round(v)
+}
+
+// roundQuotient rounds n / d, with d positive, to an integer without going through floats, so halves are exact.
+func (r roundingMode) roundQuotient(n, d int64) int64 {
+	q, rem := n/d, n%d
+	if rem == 0 {
+		return q
+	}
+	// q is truncated towards zero, away is the other neighbour
+	away := q + 1
+	if n < 0 {
+		away, rem = q-1, -rem
+	}
+	switch {
+	case 2*rem < d:
+		return q
+	case 2*rem > d:
+		return away
+	}
+
+	switch r {
+	case roundHalfEven:
+		if q%2 == 0 {
+			return q
+		}
+		return away
+	case roundHalfUp:
+		return max(q, away)
+	}
+	return away
+}
+
+// parseDecimal parses a number with one or more fractional digits into tenths, rounding any digits
+// after the first with the rounding mode. Digits beyond what fits an int64 are rejected.
+func parseDecimal(b []byte) (int64, bool) {
+	neg := len(b) > 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	dot := -1
+	for i, c := range b {
+		if c == '.' && dot < 0 {
+			dot = i
+		} else if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	if dot < 1 || dot == len(b)-1 || len(b) > 19 {
+		return 0, false
+	}
+
+	var n int64
+	for i, c := range b {
+		if i != dot {
+			n = n*10 + int64(c-'0')
+		}
+	}
+	if neg {
+		n = -n
+	}
+	extra := len(b) - dot - 2
+	if extra == 0 {
+		return n, true
+	}
+	return rounding.roundQuotient(n, int64(math.Pow10(extra))), true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundQuotient(t *testing.T) {
+	for _, tc := range []struct {
+		n, d           int64
+		away, even, up int64
+	}{
+		{n: 125, d: 10, away: 13, even: 12, up: 13},
+		{n: 135, d: 10, away: 14, even: 14, up: 14},
+		{n: -125, d: 10, away: -13, even: -12, up: -12},
+		{n: -135, d: 10, away: -14, even: -14, up: -13},
+		{n: 124, d: 10, away: 12, even: 12, up: 12},
+		{n: -126, d: 10, away: -13, even: -13, up: -13},
+		{n: 5, d: 2, away: 3, even: 2, up: 3},
+		{n: -1, d: 2, away: -1, even: 0, up: 0},
+		{n: 30, d: 10, away: 3, even: 3, up: 3},
+	} {
+		for mode, expected := range map[roundingMode]int64{roundHalfAway: tc.away, roundHalfEven: tc.even, roundHalfUp: tc.up} {
+			if got := mode.roundQuotient(tc.n, tc.d); got != expected {
+				t.Errorf("Wrong rounding of %d/%d in mode %d, expected: %d, got: %d", tc.n, tc.d, mode, expected, got)
+			}
+		}
+	}
+}
+
+func TestRoundingTwoDecimals(t *testing.T) {
+	defer func(mode roundingMode) { rounding = mode }(rounding)
+
+	// Every reading but -3.44 is a half, 12.45 and -3.45 round to different maxes and mins per mode
+	input := []byte("A;12.35\nA;12.45\nA;-3.45\nA;-3.44\nA;+1.05\nB;0.25\nB;0.25\n")
+	for _, tc := range []struct {
+		mode     string
+		expected string
+	}{
+		{mode: "half-away", expected: "{A=-3.50/3.82/12.50, B=0.30/0.30/0.30, }\n"},
+		{mode: "half-even", expected: "{A=-3.40/3.80/12.40, B=0.20/0.20/0.20, }\n"},
+		{mode: "half-up", expected: "{A=-3.40/3.84/12.50, B=0.30/0.30/0.30, }\n"},
+	} {
+		var err error
+		if rounding, err = parseRoundingMode(tc.mode); err != nil {
+			t.Fatal(err)
+		}
+		data := New()
+		(&parser{lenient: true}).process(data, input)
+
+		var sb strings.Builder
+		printMeasurements(&sb, sortedResults(data), precision{min: 2, mean: 2, max: 2}, false, "\n")
+		if sb.String() != tc.expected {
+			t.Errorf("Wrong %s rounding, expected: %q, got: %q", tc.mode, tc.expected, sb.String())
+		}
+	}
+
+	if _, err := parseRoundingMode("half-down"); err == nil {
+		t.Error("Expected an error for an unknown rounding mode")
+	}
+}
+
+func TestLibraryRounding(t *testing.T) {
+	defer func(mode roundingMode) { rounding = mode }(rounding)
+
+	// A mean of -0.25 degrees, a half in tenths
+	readings := []int64{-2, -3, -2, -3}
+	m := &measurement{sum: -10, count: 4}
+	for mode, expected := range map[roundingMode]float64{roundHalfAway: -0.3, roundHalfEven: -0.2, roundHalfUp: -0.2} {
+		rounding = mode
+
+		var red DefaultReducer
+		s := red.Init()
+		for _, r := range readings {
+			s = red.Add(s, r)
+		}
+		stats := Stats{Sum: m.sum, Count: m.count}
+		for name, got := range map[string]float64{"Stats.Mean": stats.Mean(), "DefaultReducer": red.Finalize(s), "the output": m.mean()} {
+			if got != expected {
+				t.Errorf("Wrong mean of %s with rounding mode %d, expected: %v, got: %v", name, mode, expected, got)
+			}
+		}
+	}
+}
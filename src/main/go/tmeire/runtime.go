@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// partialExitCode is the exit status when --max-runtime stopped the aggregation before the end of the input.
+const partialExitCode = 4
+
+// contextReader fails reading once ctx is done, so the aggregation stops after the blocks read before it.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
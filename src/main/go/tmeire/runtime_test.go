@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowReader returns at most 100 bytes per read, a millisecond apart.
+type slowReader struct {
+	r *strings.Reader
+}
+
+func (s slowReader) Read(b []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return s.r.Read(b[:min(len(b), 100)])
+}
+
+func largeFixture() string {
+	var sb strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%41, i%100-50, i%10)
+	}
+	return sb.String()
+}
+
+func TestMaxRuntime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	data, err := aggregate(slowReader{strings.NewReader(largeFixture())}, 256, &parser{ctx: ctx})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to stop the aggregation, got: %v", err)
+	}
+	var rows int64
+	for _, m := range data.Flatten() {
+		rows += m.count
+	}
+	if rows == 0 || rows >= 5000 {
+		t.Errorf("Expected the partial results of some of the 5000 rows, got: %d rows", rows)
+	}
+}
+
+func TestMaxRuntimeExitCode(t *testing.T) {
+	if filename := os.Getenv("CALC_MAX_RUNTIME_INPUT"); filename != "" {
+		os.Args = []string{"calc", "--max-runtime", "1ns", filename}
+		main()
+		return
+	}
+
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte(largeFixture()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMaxRuntimeExitCode$")
+	cmd.Env = append(os.Environ(), "CALC_MAX_RUNTIME_INPUT="+filename)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != partialExitCode {
+		t.Fatalf("Expected exit status %d, got: %v", partialExitCode, err)
+	}
+	if !strings.HasPrefix(stderr.String(), "partial: ") {
+		t.Errorf("Expected a partial marker on stderr, got: %q", stderr.String())
+	}
+	if string(stdout) != "{}\n" {
+		t.Errorf("Wrong partial output, expected: %q, got: %q", "{}\n", stdout)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+)
+
+// serialThreshold is the file size below which aggregateFile parses the file on the calling goroutine,
+// as setting up the workers and channels costs more than they save on small files.
+var serialThreshold int64 = 1024 * 1024
+
+// useSerial decides whether a file of size bytes takes the serial path, a variable so tests can observe it.
+var useSerial = func(size int64) bool {
+	return size < serialThreshold
+}
+
+// aggregateSerial is aggregate without any workers, reading all of r into a single block.
+func aggregateSerial(r io.Reader, p *parser) (measurements, error) {
+	r, err := prepareInput(r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		// Like readBlocks, keep the full records read before the error
+		b = b[:p.recordEnd(b)+1]
+	} else if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	data := New()
+	p.processBlock(data, b, 0)
+	return data, err
+}
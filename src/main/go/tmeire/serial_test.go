@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSerialThreshold(t *testing.T) {
+	defer func(threshold int64, decide func(int64) bool) {
+		serialThreshold, useSerial = threshold, decide
+	}(serialThreshold, useSerial)
+	serialThreshold = 1000
+
+	var serial []bool
+	decide := useSerial
+	useSerial = func(size int64) bool {
+		s := decide(size)
+		serial = append(serial, s)
+		return s
+	}
+
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name   string
+		rows   int
+		serial bool
+	}{
+		{name: "tiny", rows: 10, serial: true},
+		{name: "large", rows: 5000, serial: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			for i := 0; i < tc.rows; i++ {
+				fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%13, i%100-50, i%10)
+			}
+			// The last line has no newline
+			input := strings.TrimSuffix(sb.String(), "\n")
+			filename := filepath.Join(dir, tc.name+".txt")
+			if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			serial = nil
+			data, err := aggregateFile(filename, &parser{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(serial) != 1 || serial[0] != tc.serial {
+				t.Errorf("Wrong path for %d rows, expected serial: %v, got: %v", tc.rows, tc.serial, serial)
+			}
+
+			expected := New()
+			process(expected, []byte(input+"\n"))
+			if !maps.Equal(data.Stats(), expected.Stats()) {
+				t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+			}
+		})
+	}
+}
+
+func TestSerialThresholdGzip(t *testing.T) {
+	defer func(decide func(int64) bool) { useSerial = decide }(useSerial)
+	useSerial = func(size int64) bool {
+		t.Errorf("Expected the size of the gzipped file to be ignored, got: %d", size)
+		return true
+	}
+
+	// Far less than the threshold gzipped, but more once decompressed
+	input := strings.Repeat("Hamburg;12.0\n", 200000)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(input))
+	zw.Close()
+	filename := filepath.Join(t.TempDir(), "measurements.txt.gz")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := aggregateFile(filename, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Stats{"Hamburg": {Min: 120, Max: 120, Sum: 120 * 200000, Count: 200000}}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected, data.Stats())
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// shmDir is where Linux keeps the POSIX shared memory segments, shm_open(name) opens shmDir/name.
+const shmDir = "/dev/shm"
+
+// openShared maps size bytes from offset of the shared memory segment name read-only, or up to the end
+// of the segment if size is 0. Unmapping it with the returned func invalidates the station names pointing into it.
+func openShared(name string, offset, size int64) ([]byte, func() error, error) {
+	f, err := os.Open(filepath.Join(shmDir, filepath.Base(name)))
+	if err != nil {
+		return nil, nil, err
+	}
+	// The mapping stays valid after closing the file
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if size == 0 {
+		size = info.Size() - offset
+	}
+	if offset < 0 || size <= 0 || offset+size > info.Size() {
+		return nil, nil, fmt.Errorf("can't map %d bytes at offset %d of the %d byte segment %s", size, offset, info.Size(), name)
+	}
+
+	// Mappings start at a page boundary, so map from the page of the offset and skip the bytes before it
+	start := offset &^ int64(os.Getpagesize()-1)
+	b, err := syscall.Mmap(int(f.Fd()), start, int(offset+size-start), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b[offset-start:], func() error { return syscall.Munmap(b) }, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestAggregateShared(t *testing.T) {
+	if _, err := os.Stat(shmDir); err != nil {
+		t.Skipf("No shared memory: %v", err)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%31, i%100-50, i%10)
+	}
+	header := "HDR\n"
+	// The last line has no newline
+	input := strings.TrimSuffix(sb.String(), "\n")
+
+	// Create the segment and write to it through a shared mapping, like another process would
+	name := fmt.Sprintf("calc-test-%d", os.Getpid())
+	path := filepath.Join(shmDir, name)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	size := len(header) + len(input)
+	if err := f.Truncate(int64(size)); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(segment, header+input)
+	defer syscall.Munmap(segment)
+
+	b, unmap, err := openShared(name, int64(len(header)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unmap()
+	if string(b) != input {
+		t.Fatalf("Wrong mapped bytes, expected %d bytes, got %d", len(input), len(b))
+	}
+
+	expected := New()
+	process(expected, []byte(input+"\n"))
+	for _, workers := range []int{1, 3} {
+		if data := aggregateBytes(b, workers, &parser{}); !maps.Equal(data.Stats(), expected.Stats()) {
+			t.Errorf("Wrong aggregation of the segment with %d workers, expected: %v, got: %v", workers, expected.Stats(), data.Stats())
+		}
+	}
+
+	if _, _, err := openShared(name, 0, int64(size+1)); err == nil {
+		t.Errorf("Expected an error mapping beyond the end of the segment")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func openShared(name string, offset, size int64) ([]byte, func() error, error) {
+	return nil, nil, errors.New("shared memory segments are only supported on Linux")
+}
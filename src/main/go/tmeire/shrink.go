@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errInputChanged is returned when the input file ends before the size it had when it was opened,
+// as when it's truncated by another process while being read.
+var errInputChanged = errors.New("input file changed while reading")
+
+// sizeChecker fails with errInputChanged if r ends before size bytes are read from it.
+type sizeChecker struct {
+	r          io.Reader
+	size, read int64
+}
+
+func (c *sizeChecker) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.read += int64(n)
+	if errors.Is(err, io.EOF) && c.read < c.size {
+		return n, fmt.Errorf("%w: it had %d bytes when opened, but ended after %d", errInputChanged, c.size, c.read)
+	}
+	return n, err
+}
+
+// checkSize wraps a regular file in a sizeChecker for its current size, reading it with directInput.
+// Other files, like stdin, are returned as is.
+func checkSize(file *os.File) (io.Reader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return file, nil
+	}
+	return &sizeChecker{r: directInput(file), size: info.Size()}, nil
+}
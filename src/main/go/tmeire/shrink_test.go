@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSizeChecker(t *testing.T) {
+	input := "Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\n"
+
+	// The reader ends after 26 bytes, as a file truncated while reading
+	data, err := aggregate(&sizeChecker{r: io.LimitReader(strings.NewReader(input), 26), size: int64(len(input))}, 16, &parser{})
+	if !errors.Is(err, errInputChanged) {
+		t.Fatalf("Expected an input changed error, got: %v", err)
+	}
+	expected := "input file changed while reading: it had 41 bytes when opened, but ended after 26"
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("Wrong error, expected it to contain: %s, got: %v", expected, err)
+	}
+	if got := data.Stats(); len(got) != 2 {
+		t.Errorf("Expected the results read before the truncation, got: %v", got)
+	}
+
+	data, err = aggregate(&sizeChecker{r: strings.NewReader(input), size: int64(len(input))}, 16, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := data.Stats(); len(got) != 3 {
+		t.Errorf("Wrong aggregation of the whole input: %v", got)
+	}
+}
+
+func TestAggregateTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(path, []byte("Hamburg;12.0\nBulawayo;8.9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := checkSize(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncate the file after it's opened but before it's read
+	if err := os.Truncate(path, 13); err != nil {
+		t.Fatal(err)
+	}
+	data, err := aggregate(r, 16, &parser{})
+	if !errors.Is(err, errInputChanged) {
+		t.Fatalf("Expected an input changed error, got: %v", err)
+	}
+	expected := map[string]Stats{"Hamburg": {Min: 120, Max: 120, Sum: 120, Count: 1}}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation, expected: %v, got: %v", expected, data.Stats())
+	}
+}
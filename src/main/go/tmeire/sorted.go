@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// sortChecker verifies that the station names of the name;temperature lines read through it are non-decreasing.
+// Reading fails on the first line with a name sorting before the one on the line before it.
+type sortChecker struct {
+	r    io.Reader
+	line int
+	prev []byte
+	// partial is the start of a line continued by the next read.
+	partial []byte
+}
+
+func (c *sortChecker) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	data := b[:n]
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			c.partial = append(c.partial, data...)
+			break
+		}
+
+		line := data[:idx]
+		if len(c.partial) > 0 {
+			line = append(c.partial, line...)
+		}
+		if cerr := c.check(line); cerr != nil {
+			return n, cerr
+		}
+		c.partial = c.partial[:0]
+		data = data[idx+1:]
+	}
+
+	if err == io.EOF && len(c.partial) > 0 {
+		if cerr := c.check(c.partial); cerr != nil {
+			return n, cerr
+		}
+		c.partial = c.partial[:0]
+	}
+	return n, err
+}
+
+func (c *sortChecker) check(line []byte) error {
+	c.line++
+	if len(line) == 0 {
+		return nil
+	}
+
+	name, _, _ := bytes.Cut(line, []byte{';'})
+	if c.prev != nil && bytes.Compare(name, c.prev) < 0 {
+		return fmt.Errorf("input is not sorted: line %d has station %s after %s", c.line, name, c.prev)
+	}
+	c.prev = append(c.prev[:0], name...)
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCheckSorted(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "sorted", input: "Bulawayo;8.9\nBulawayo;10.1\n\nHamburg;12.0\nPalembang;38.8"},
+		{name: "out of order", input: "Bulawayo;8.9\nHamburg;12.0\nHamburg;-3.4\nBulawayo;10.1\nPalembang;38.8\n", err: "input is not sorted: line 4 has station Bulawayo after Hamburg"},
+		{name: "last line", input: "Hamburg;12.0\nBulawayo;10.1", err: "input is not sorted: line 2 has station Bulawayo after Hamburg"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Read a byte at a time to have lines spanning reads
+			_, err := io.ReadAll(&sortChecker{r: iotest.OneByteReader(strings.NewReader(tc.input))})
+			if tc.err == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Errorf("Wrong error, expected: %s, got: %v", tc.err, err)
+			}
+		})
+	}
+
+	if _, err := aggregate(strings.NewReader("Hamburg;12.0\nBulawayo;10.1\n"), 16, &parser{checkSorted: true}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected the aggregation to fail on line 2, got: %v", err)
+	}
+}
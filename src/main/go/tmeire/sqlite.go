@@ -0,0 +1,38 @@
+package main
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// writeSQLite creates the results table in the SQLite database at path, creating the database if needed,
+// and inserts the results in a single transaction.
+func writeSQLite(path string, results []*measurement) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("CREATE TABLE results (station TEXT, min REAL, mean REAL, max REAL, count INTEGER)"); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO results (station, min, mean, max, count) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, m := range results {
+		if _, err := stmt.Exec(string(m.name), float64(m.min)/10., m.mean(), float64(m.max)/10., m.count); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
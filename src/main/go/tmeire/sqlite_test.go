@@ -0,0 +1,55 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	data := New()
+	process(data, []byte("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\n"))
+
+	path := filepath.Join(t.TempDir(), "out.db")
+	if err := writeSQLite(path, sortedResults(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT station, min, mean, max, count FROM results ORDER BY station")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		station        string
+		min, mean, max float64
+		count          int64
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.station, &r.min, &r.mean, &r.max, &r.count); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []row{
+		{"Bulawayo", 8.9, 8.9, 8.9, 1},
+		{"Hamburg", -3.4, 4.3, 12.0, 2},
+		{"Palembang", 38.8, 38.8, 38.8, 1},
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Wrong rows, expected: %v, got: %v", expected, got)
+	}
+}
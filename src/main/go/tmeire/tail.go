@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+const tailChunkSize = 64 * 1024
+
+// tailRows returns the last n lines of r, which is size bytes long, by scanning backwards from its end
+// for line boundaries. The returned lines always end with a newline.
+func tailRows(r io.ReaderAt, size int64, n int, chunkSize int) ([]byte, error) {
+	if n <= 0 || size == 0 {
+		return nil, nil
+	}
+
+	// Don't count the newline ending the last line
+	end := size
+	var last [1]byte
+	if _, err := r.ReadAt(last[:], size-1); err != nil {
+		return nil, err
+	}
+	if last[0] == '\n' {
+		end--
+	}
+
+	start := int64(0)
+	chunk := make([]byte, chunkSize)
+	lines := 0
+scan:
+	for pos := end; pos > 0; {
+		l := min(int64(chunkSize), pos)
+		pos -= l
+		if _, err := r.ReadAt(chunk[:l], pos); err != nil {
+			return nil, err
+		}
+		for i := l - 1; i >= 0; i-- {
+			if chunk[i] == '\n' {
+				lines++
+				if lines == n {
+					start = pos + i + 1
+					break scan
+				}
+			}
+		}
+	}
+
+	b := make([]byte, end-start, end-start+1)
+	if _, err := r.ReadAt(b, start); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.HasSuffix(b, []byte{'\n'}) {
+		b = append(b, '\n')
+	}
+	return b, nil
+}
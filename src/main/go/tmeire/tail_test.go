@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestTailRows(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "Station%d;%d.%d\n", i%37, i%100, i%10)
+	}
+	input := sb.String()
+	lines := strings.SplitAfter(input, "\n")
+	lines = lines[:len(lines)-1]
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		rows  int
+		tail  string
+	}{
+		{name: "last 100", input: input, rows: 100, tail: strings.Join(lines[900:], "")},
+		{name: "no trailing newline", input: strings.TrimSuffix(input, "\n"), rows: 100, tail: strings.Join(lines[900:], "")},
+		{name: "more rows than the file", input: input, rows: 5000, tail: input},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tailRows(strings.NewReader(tc.input), int64(len(tc.input)), tc.rows, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, []byte(tc.tail)) {
+				t.Fatalf("Wrong tail, expected %d bytes, got %d", len(tc.tail), len(b))
+			}
+
+			data, expected := New(), New()
+			process(data, b)
+			process(expected, []byte(tc.tail))
+			if !maps.Equal(data.Stats(), expected.Stats()) {
+				t.Errorf("Wrong aggregation, expected: %v, got: %v", expected.Stats(), data.Stats())
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// aggregateTar aggregates every regular file in the tar archive, or stdin for "-", as a shard and merges the results.
+// The archive and every entry in it are decompressed first if they're gzipped.
+func aggregateTar(filename string, blockSize int, p *parser) (measurements, error) {
+	file, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return aggregateShards(file, blockSize, p)
+}
+
+func aggregateShards(r io.Reader, blockSize int, p *parser) (measurements, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := New()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		res, err := aggregate(tr, blockSize, p)
+		data.Merge(res)
+		if err != nil {
+			return data, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"maps"
+	"testing"
+)
+
+func TestAggregateShards(t *testing.T) {
+	shards := []struct {
+		name, data string
+	}{
+		{"shards/1.txt", "Hamburg;12.0\nBulawayo;8.9\n"},
+		{"shards/2.txt", "Hamburg;-3.4\nPalembang;38.8\n"},
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "shards/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shards {
+		if err := tw.WriteHeader(&tar.Header{Name: s.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(s.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(s.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Stats{
+		"Hamburg":   {Min: -34, Max: 120, Sum: 86, Count: 2},
+		"Bulawayo":  {Min: 89, Max: 89, Sum: 89, Count: 1},
+		"Palembang": {Min: 388, Max: 388, Sum: 388, Count: 1},
+	}
+	data, err := aggregateShards(bytes.NewReader(archive.Bytes()), 16, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation of the tar, expected: %v, got: %v", expected, data.Stats())
+	}
+
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	gw.Write(archive.Bytes())
+	gw.Close()
+	data, err = aggregateShards(&compressed, 16, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong aggregation of the gzipped tar, expected: %v, got: %v", expected, data.Stats())
+	}
+}
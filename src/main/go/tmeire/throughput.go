@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// throughput counts the rows and bytes processed by the workers, updated once per block to keep the overhead low.
+type throughput struct {
+	rows, bytes atomic.Int64
+}
+
+func (t *throughput) add(rows, bytes int) {
+	t.rows.Add(int64(rows))
+	t.bytes.Add(int64(bytes))
+}
+
+// report calls fn with the totals so far every interval, until stop is closed.
+func (t *throughput) report(interval time.Duration, stop <-chan struct{}, fn func(rows, bytes int64)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			fn(t.rows.Load(), t.bytes.Load())
+		}
+	}
+}
+
+// printRates returns a report callback printing the rows/sec and MB/sec since the previous call to w.
+func printRates(w io.Writer, interval time.Duration) func(rows, bytes int64) {
+	var prevRows, prevBytes int64
+	return func(rows, bytes int64) {
+		secs := interval.Seconds()
+		fmt.Fprintf(w, "rows/sec=%.0f MB/sec=%.1f\n", float64(rows-prevRows)/secs, float64(bytes-prevBytes)/secs/1e6)
+		prevRows, prevBytes = rows, bytes
+	}
+}
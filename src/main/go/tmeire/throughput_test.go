@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThroughputReport(t *testing.T) {
+	tp := &throughput{}
+	stop := make(chan struct{})
+	defer close(stop)
+	reports := make(chan int64)
+	go tp.report(time.Millisecond, stop, func(rows, bytes int64) {
+		select {
+		case reports <- rows:
+		case <-stop:
+		}
+	})
+
+	var prev int64
+	for i := 0; i < 3; i++ {
+		tp.add(10, 130)
+		// Skip the reports from before the rows were added
+		rows := <-reports
+		for rows == prev {
+			rows = <-reports
+		}
+		if rows <= prev {
+			t.Errorf("Expected increasing row counts, got %d after %d", rows, prev)
+		}
+		prev = rows
+	}
+
+	p := &parser{throughput: &throughput{}}
+	if _, err := collectData(strings.NewReader("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"), 16, 2, p); err != nil {
+		t.Fatal(err)
+	}
+	if rows, bytes := p.throughput.rows.Load(), p.throughput.bytes.Load(); rows != 3 || bytes != 39 {
+		t.Errorf("Wrong totals, expected: 3 rows and 39 bytes, got: %d and %d", rows, bytes)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// tieStats counts how often a reading ties the min or max of its station versus strictly beating it,
+// which shows how concentrated the readings are at the extremes. It also checks the min never goes up
+// and the max never goes down, counting the updates that do.
+type tieStats struct {
+	minTied, minBeaten atomic.Int64
+	maxTied, maxBeaten atomic.Int64
+	violations         atomic.Int64
+}
+
+// add adds the reading to data like measurements.Add, comparing it with the min and max before the update.
+func (s *tieStats) add(data measurements, name []byte, temperature int64) *measurement {
+	hash := namehash(name)
+	prev := data.bucket(hash).find(name, hash)
+	if prev == nil {
+		return data.Add(name, temperature)
+	}
+
+	oldMin, oldMax := prev.min, prev.max
+	switch {
+	case temperature == oldMin:
+		s.minTied.Add(1)
+	case temperature < oldMin:
+		s.minBeaten.Add(1)
+	}
+	switch {
+	case temperature == oldMax:
+		s.maxTied.Add(1)
+	case temperature > oldMax:
+		s.maxBeaten.Add(1)
+	}
+
+	m := data.Add(name, temperature)
+	if m.min > oldMin || m.max < oldMax || m.min > temperature || m.max < temperature {
+		s.violations.Add(1)
+	}
+	return m
+}
+
+func (s *tieStats) String() string {
+	return fmt.Sprintf("min tied=%d beaten=%d, max tied=%d beaten=%d, non-monotonic updates=%d",
+		s.minTied.Load(), s.minBeaten.Load(), s.maxTied.Load(), s.maxBeaten.Load(), s.violations.Load())
+}
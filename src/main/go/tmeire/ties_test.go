@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTieStats(t *testing.T) {
+	input := []byte("Hamburg;12.0\nHamburg;12.0\nHamburg;-3.4\nHamburg;-3.4\nHamburg;5.0\nHamburg;12.0\nHamburg;13.1\n" +
+		"Bulawayo;8.9\nBulawayo;8.9\nBulawayo;-1.0\n")
+
+	p := &parser{ties: &tieStats{}}
+	data := New()
+	p.process(data, input)
+
+	for _, tc := range []struct {
+		name          string
+		got, expected int64
+	}{
+		// Both stations repeat their first reading, which ties the min and the max at once
+		{name: "min tied", got: p.ties.minTied.Load(), expected: 3},
+		{name: "min beaten", got: p.ties.minBeaten.Load(), expected: 2},
+		{name: "max tied", got: p.ties.maxTied.Load(), expected: 3},
+		{name: "max beaten", got: p.ties.maxBeaten.Load(), expected: 1},
+		{name: "non-monotonic updates", got: p.ties.violations.Load(), expected: 0},
+	} {
+		if tc.got != tc.expected {
+			t.Errorf("Wrong %s count, expected: %d, got: %d", tc.name, tc.expected, tc.got)
+		}
+	}
+	if got := data.Stats()["Hamburg"]; got.Min != -34 || got.Max != 131 || got.Count != 7 {
+		t.Errorf("Wrong aggregation with the tie stats, got: %v", got)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// warmup reads the whole file once, so it's in the page cache before the timed aggregation starts and
+// the disk latency doesn't end up in the parse timings. It returns the number of bytes read.
+func warmup(filename string) (int64, error) {
+	file, err := openSequential(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(io.Discard, file)
+}
+
+// touchPages reads a byte of every page of b to fault in a mapping before aggregating it. It returns
+// the sum of the bytes read so the reads can't be optimised away.
+func touchPages(b []byte) byte {
+	var sum byte
+	for i := 0; i < len(b); i += os.Getpagesize() {
+		sum += b[i]
+	}
+	return sum
+}
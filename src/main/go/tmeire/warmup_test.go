@@ -0,0 +1,44 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWarmup(t *testing.T) {
+	input := string(benchmarkInput()[:50000])
+	filename := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := warmup(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(input)) {
+		t.Errorf("Expected the warmup to read the whole file, expected: %d bytes, got: %d", len(input), n)
+	}
+
+	data, err := aggregateFile(filename, &parser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := New()
+	process(expected, []byte(input))
+	if !maps.Equal(data.Stats(), expected.Stats()) {
+		t.Errorf("Wrong aggregation after the warmup, expected: %v, got: %v", expected.Stats(), data.Stats())
+	}
+}
+
+func TestTouchPages(t *testing.T) {
+	page := os.Getpagesize()
+	b := []byte(strings.Repeat("x", 3*page+1))
+	b[0], b[page], b[2*page], b[3*page] = 1, 2, 3, 4
+	if got := touchPages(b); got != 10 {
+		t.Errorf("Expected the first byte of every page to be read, expected a sum of 10, got: %d", got)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watch aggregates the .gz segments in dir, and every one appearing in it later, into a running aggregate.
+// It passes a sorted snapshot of the results to snapshot every interval and whenever a value is received
+// from refresh, until stop is closed. New and written segments are reported by fsnotify, and read once they
+// haven't been written to for followPoll. A segment that's still being written fails to decompress and is
+// read again after its next write. Segments that can't be read for any other reason are reported to warn
+// and skipped.
+func watch(dir string, interval time.Duration, p *parser, refresh <-chan os.Signal, stop <-chan struct{}, warn io.Writer, snapshot func([]*measurement)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	// Watch before listing the directory, so no segment appears in between unnoticed
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	data := New()
+	seen := map[string]bool{}
+	read := func(name string) {
+		if !strings.HasSuffix(name, ".gz") || seen[name] {
+			return
+		}
+		path := filepath.Join(dir, name)
+		// A segment that was just created only has data after one of its next writes
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+			return
+		}
+		segment, err := aggregateFile(path, p)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return
+		}
+		seen[name] = true
+		if err != nil {
+			fmt.Fprintf(warn, "warning: skipping segment %s: %v\n", name, err)
+			return
+		}
+		data.Merge(segment)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		read(e.Name())
+	}
+
+	// The segments written to since the last read, read once the writes settle
+	pending := map[string]bool{}
+	settle := time.NewTimer(followPoll)
+	settle.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				pending[filepath.Base(event.Name)] = true
+				settle.Reset(followPoll)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-settle.C:
+			for name := range pending {
+				read(name)
+			}
+			clear(pending)
+		case <-ticker.C:
+			snapshot(sortedResults(data))
+		case <-refresh:
+			snapshot(sortedResults(data))
+		case <-stop:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	io.WriteString(zw, s)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "segment-1.gz"), gzipped(t, "Hamburg;12.0\nBulawayo;8.9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	refresh := make(chan os.Signal, 1)
+
+	snapshots := make(chan map[string]int64)
+	go watch(dir, 5*time.Millisecond, &parser{}, refresh, stop, io.Discard, func(results []*measurement) {
+		counts := make(map[string]int64)
+		for _, m := range results {
+			counts[string(m.name)] = m.count
+		}
+		select {
+		case snapshots <- counts:
+		case <-stop:
+		}
+	})
+
+	waitFor := func(expected map[string]int64) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		var counts map[string]int64
+		for {
+			select {
+			case counts = <-snapshots:
+				if maps.Equal(counts, expected) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("No snapshot with the counts %v, the last one was %v", expected, counts)
+			}
+		}
+	}
+	waitFor(map[string]int64{"Hamburg": 1, "Bulawayo": 1})
+
+	// A segment still being written is read once it's complete, other files are ignored
+	segment := gzipped(t, "Hamburg;-3.4\nPalembang;38.8\nHamburg;1.0\n")
+	if err := os.WriteFile(filepath.Join(dir, "segment-2.gz"), segment[:len(segment)-10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("Hamburg;1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "segment-2.gz"), segment, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(map[string]int64{"Hamburg": 3, "Bulawayo": 1, "Palembang": 1})
+
+	if err := os.WriteFile(filepath.Join(dir, "segment-3.gz"), gzipped(t, "Bulawayo;10.1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	refresh <- os.Interrupt
+	waitFor(map[string]int64{"Hamburg": 3, "Bulawayo": 2, "Palembang": 1})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// weightedMean is the confidence-weighted mean of a station over merged dumps: the mean of every dump
+// with the station, weighted by the weight of the dump. Unlike the mean it ignores how many readings every
+// dump has, and the weights of the dumps without the station don't count, so a station in a single dump
+// has its mean there as its weighted mean. The min, max and count are merged as always.
+type weightedMean struct {
+	sum, weight float64
+}
+
+// Mean returns the weighted mean in degrees.
+func (w *weightedMean) Mean() float64 {
+	return w.sum / w.weight
+}
+
+// weightedMeanColumn is the weighted_mean column of calc merge --weights.
+var weightedMeanColumn = column{"weighted_mean", func(m *measurement) string {
+	if m.weighted == nil {
+		return formatDegrees(m.mean())
+	}
+	return formatDegrees(m.weighted.Mean())
+}}
+
+// parseMergeArgs parses the flags of calc merge, the --weights of the dumps, followed by the dump paths.
+func parseMergeArgs(args []string) (weights []float64, paths []string, err error) {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.Func("weights", "comma separated confidence `weights` of the dumps, like 0.7,0.3, for the weighted_mean column", func(s string) error {
+		weights, err = parseWeights(s)
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if weights != nil && len(weights) != fs.NArg() {
+		return nil, nil, fmt.Errorf("got %d weights for %d dumps", len(weights), fs.NArg())
+	}
+	return weights, fs.Args(), nil
+}
+
+// parseWeights parses a comma separated list of positive weights.
+func parseWeights(s string) ([]float64, error) {
+	var weights []float64
+	for _, f := range strings.Split(s, ",") {
+		w, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		if !(w > 0) {
+			return nil, fmt.Errorf("invalid weight %v, expected it above 0", w)
+		}
+		weights = append(weights, w)
+	}
+	return weights, nil
+}
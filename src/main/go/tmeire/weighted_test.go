@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeWeights(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+
+	// Hamburg has a mean of 10.0 over 1 reading in a and 20.0 over 3 readings in b, Abha is only in b
+	if err := writeBufferedDumpFile(a, []*measurement{{name: []byte("Hamburg"), min: 100, max: 100, sum: 100, count: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBufferedDumpFile(b, []*measurement{
+		{name: []byte("Abha"), min: 50, max: 150, sum: 200, count: 2},
+		{name: []byte("Hamburg"), min: 150, max: 250, sum: 600, count: 3},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	weights, paths, err := parseMergeArgs([]string{"--weights", "0.7,0.3", a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := New()
+	if err := mergeDumps(data, paths, weights, false, 0, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	// The min, max and count stay exact
+	expected := map[string]Stats{
+		"Abha":    {Min: 50, Max: 150, Sum: 200, Count: 2},
+		"Hamburg": {Min: 100, Max: 250, Sum: 700, Count: 4},
+	}
+	if !maps.Equal(data.Stats(), expected) {
+		t.Errorf("Wrong weighted merge, expected: %v, got: %v", expected, data.Stats())
+	}
+
+	var sb strings.Builder
+	if err := writeCSV(&sb, sortedResults(data), append(columns[:4:4], weightedMeanColumn), "\n"); err != nil {
+		t.Fatal(err)
+	}
+	// Hamburg: 0.7*10.0 + 0.3*20.0 = 13.0, instead of the mean of 17.5 over its readings
+	if out := "station,min,mean,max,weighted_mean\nAbha,5.0,10.0,15.0,10.0\nHamburg,10.0,17.5,25.0,13.0\n"; sb.String() != out {
+		t.Errorf("Wrong weighted output, expected: %q, got: %q", out, sb.String())
+	}
+
+	for _, args := range [][]string{{"--weights", "1", a, b}, {"--weights", "1,-1", a, b}, {"--weights", "1,x", a, b}} {
+		if _, _, err := parseMergeArgs(args); err == nil {
+			t.Errorf("Expected an error for the merge arguments %q", args)
+		}
+	}
+}
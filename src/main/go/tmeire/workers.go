@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// workerCount overrides the number of parsing goroutines if positive, set by --workers.
+var workerCount = 0
+
+// usableCPUs returns the number of CPUs to use, a variable so tests can simulate other machines.
+var usableCPUs = func() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// defaultWorkers returns the number of parsing goroutines: one per usable CPU,
+// except for the one left for reading the file, but at least one.
+func defaultWorkers() int {
+	if workerCount > 0 {
+		return workerCount
+	}
+	return max(usableCPUs()-1, 1)
+}
+
+// workersFor returns the number of parsing goroutines for a file of size bytes read in blocks of blockSize.
+// A worker only ever has one block to parse, so on a machine with many CPUs a small file gets fewer workers
+// than the default instead of spinning up hundreds of idle goroutines.
+func workersFor(size int64, blockSize int) int {
+	if workerCount > 0 {
+		return workerCount
+	}
+	blocks := (size + int64(blockSize) - 1) / int64(blockSize)
+	return int(min(max(blocks, 1), int64(defaultWorkers())))
+}
+
+// configureProcs sets GOMAXPROCS to n if it's positive. Otherwise, unless the GOMAXPROCS environment
+// variable is set, it's lowered to the CPU quota of the cgroup the process runs in, as NumCPU ignores those.
+func configureProcs(n int) {
+	if n > 0 {
+		runtime.GOMAXPROCS(n)
+		return
+	}
+	if os.Getenv("GOMAXPROCS") != "" {
+		return
+	}
+	if limit, ok := cgroupCPULimit("/sys/fs/cgroup"); ok && limit < runtime.GOMAXPROCS(0) {
+		runtime.GOMAXPROCS(limit)
+	}
+}
+
+// cgroupCPULimit returns the CPU quota of the cgroup mounted at root, rounded up to whole CPUs.
+// Both the cgroup v2 cpu.max and the cgroup v1 cfs quota files are supported.
+func cgroupCPULimit(root string) (int, bool) {
+	var quota, period string
+	if b, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		quota, period, _ = strings.Cut(strings.TrimSpace(string(b)), " ")
+	} else {
+		q, err1 := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+		p, err2 := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		quota, period = strings.TrimSpace(string(q)), strings.TrimSpace(string(p))
+	}
+
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	// An unlimited quota is "max" for v2 and -1 for v1
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return 0, false
+	}
+	return max(int(math.Ceil(q/p)), 1), true
+}
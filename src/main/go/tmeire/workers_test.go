@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDefaultWorkersFollowsGOMAXPROCS(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	for _, tc := range []struct {
+		procs, workers int
+	}{
+		{procs: 1, workers: 1},
+		{procs: 2, workers: 1},
+		{procs: 4, workers: 3},
+	} {
+		configureProcs(tc.procs)
+		if got := defaultWorkers(); got != tc.workers {
+			t.Errorf("Wrong worker count for GOMAXPROCS %d, expected: %d, got: %d", tc.procs, tc.workers, got)
+		}
+	}
+}
+
+func TestCgroupCPULimit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		limit int
+		ok    bool
+	}{
+		{name: "v2 quota", files: map[string]string{"cpu.max": "150000 100000\n"}, limit: 2, ok: true},
+		{name: "v2 unlimited", files: map[string]string{"cpu.max": "max 100000\n"}},
+		{name: "v1 quota", files: map[string]string{"cpu/cpu.cfs_quota_us": "300000\n", "cpu/cpu.cfs_period_us": "100000\n"}, limit: 3, ok: true},
+		{name: "v1 unlimited", files: map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n"}},
+		{name: "no cgroup"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			limit, ok := cgroupCPULimit(root)
+			if limit != tc.limit || ok != tc.ok {
+				t.Errorf("Wrong limit, expected: %d/%v, got: %d/%v", tc.limit, tc.ok, limit, ok)
+			}
+		})
+	}
+}
+
+func TestWorkersFor(t *testing.T) {
+	defer func(cpus func() int, n int) { usableCPUs, workerCount = cpus, n }(usableCPUs, workerCount)
+	// A big cloud host
+	usableCPUs = func() int { return 384 }
+
+	for _, tc := range []struct {
+		name     string
+		size     int64
+		override int
+		workers  int
+	}{
+		{name: "empty file", size: 0, workers: 1},
+		{name: "small file", size: 20 << 20, workers: 1},
+		{name: "a few blocks", size: 5*blockSize + 1, workers: 6},
+		{name: "huge file", size: 1000 * blockSize, workers: 383},
+		{name: "override", size: 20 << 20, override: 16, workers: 16},
+	} {
+		workerCount = tc.override
+		if got := workersFor(tc.size, blockSize); got != tc.workers {
+			t.Errorf("Wrong worker count for the %s, expected: %d, got: %d", tc.name, tc.workers, got)
+		}
+	}
+}